@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// admissionPolicy decides which connections may start a session: browsers
+// must come from an allowed origin, and the number of concurrent sessions is
+// capped globally and per client IP.
+type admissionPolicy struct {
+	allowedOrigins map[string]bool // normalized origins; "*" allows any
+	maxSessions    int             // 0 means unlimited
+	maxPerIP       int             // 0 means unlimited
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+// admission is the policy applied by every frontend.
+var admission = newAdmissionPolicy(nil, 0, 0)
+
+func newAdmissionPolicy(origins []string, maxSessions, maxPerIP int) *admissionPolicy {
+	a := &admissionPolicy{
+		allowedOrigins: make(map[string]bool),
+		maxSessions:    maxSessions,
+		maxPerIP:       maxPerIP,
+		perIP:          make(map[string]int),
+	}
+	for _, o := range origins {
+		if o = strings.TrimSpace(o); o != "" {
+			a.allowedOrigins[normalizeOrigin(o)] = true
+		}
+	}
+	return a
+}
+
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(origin), "/")
+}
+
+// checkOrigin is the upgrader's CheckOrigin. Requests without an Origin
+// header (non-browser clients) and same-origin requests are always allowed.
+func (a *admissionPolicy) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || a.allowedOrigins["*"] {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if a.allowedOrigins[normalizeOrigin(origin)] {
+		return true
+	}
+	slog.Warn("admission: origin not allowed", "ip", requestIP(r), "origin", origin)
+	return false
+}
+
+// admit reserves a session slot for ip. On success the returned function
+// releases it; otherwise the error is the message shown to the visitor.
+func (a *admissionPolicy) admit(ip, frontend string) (release func(), err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.maxSessions > 0 && a.total >= a.maxSessions {
+		slog.Warn("admission: server full", "ip", ip, "frontend", frontend, "sessions", a.total, "max", a.maxSessions)
+		return nil, fmt.Errorf("the server is full right now, please try again in a few minutes")
+	}
+	if a.maxPerIP > 0 && a.perIP[ip] >= a.maxPerIP {
+		slog.Warn("admission: too many sessions for address", "ip", ip, "frontend", frontend, "sessions", a.perIP[ip], "max", a.maxPerIP)
+		return nil, fmt.Errorf("too many open sessions from your address, close one and try again")
+	}
+	a.total++
+	a.perIP[ip]++
+	slog.Info("admission: accepted", "ip", ip, "frontend", frontend, "sessions", a.total)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.total--
+			if a.perIP[ip]--; a.perIP[ip] <= 0 {
+				delete(a.perIP, ip)
+			}
+		})
+	}, nil
+}
+
+// clientIP strips the port from a remote address.
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// trustedProxies are the reverse proxies whose X-Forwarded-For is believed.
+// Without them every visitor behind the proxy shares its address, and so its
+// per-IP session limit.
+var trustedProxies []netip.Prefix
+
+// parseProxies parses addresses and CIDR ranges, e.g. "10.0.0.0/8,::1".
+func parseProxies(list []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range list {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an address or CIDR range", s)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestIP returns the client IP of an HTTP request. If the peer is a
+// trusted proxy, it is the rightmost X-Forwarded-For entry that isn't one,
+// since only the entries appended by trusted proxies can be believed.
+func requestIP(r *http.Request) string {
+	ip := clientIP(r.RemoteAddr)
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if _, err := netip.ParseAddr(hop); err != nil {
+			break
+		}
+		ip = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequestIP(t *testing.T) {
+	proxies, err := parseProxies([]string{"10.0.0.0/8", "::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedProxies = proxies
+	t.Cleanup(func() { trustedProxies = nil })
+
+	tests := []struct {
+		name, remote string
+		forwarded    []string
+		want         string
+	}{
+		{"direct", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"trusted IPv6 proxy", "[::1]:5000", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entry", "10.1.2.3:5000", []string{"192.0.2.9, 198.51.100.1"}, "198.51.100.1"},
+		{"proxy chain", "10.1.2.3:5000", []string{"198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"several headers", "10.1.2.3:5000", []string{"198.51.100.1", "10.9.9.9"}, "198.51.100.1"},
+		{"no header", "10.1.2.3:5000", nil, "10.1.2.3"},
+		{"garbage", "10.1.2.3:5000", []string{"unknown"}, "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/ws", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := requestIP(r); got != tt.want {
+				t.Errorf("requestIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	if _, err := parseProxies([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}); err != nil {
+		t.Errorf("valid list rejected: %v", err)
+	}
+	if _, err := parseProxies([]string{"proxy.example"}); err == nil {
+		t.Error("host name accepted")
+	}
+}
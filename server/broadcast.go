@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// adminToken authorizes requests to the /admin endpoints. Empty disables them.
+var adminToken string
+
+// maxBroadcastLen caps an announcement, in bytes.
+const maxBroadcastLen = 1024
+
+// promptLine is the shell's input line. While the shell waits at its prompt,
+// an announcement is printed above the line and the prompt redrawn, so
+// whatever the visitor was typing stays put. The shell's echo goes through
+// it too, keeping the two from interleaving.
+type promptLine struct {
+	mu     sync.Mutex
+	active bool
+	prompt string
+	line   []rune
+}
+
+// show writes the prompt and starts a new line.
+func (p *promptLine) show(w io.Writer, prompt string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active, p.prompt, p.line = true, prompt, nil
+	fmt.Fprint(w, prompt)
+}
+
+// insert appends r to the line and echoes it.
+func (p *promptLine) insert(w io.Writer, r rune) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.line = append(p.line, r)
+	fmt.Fprint(w, string(r))
+}
+
+// backspace removes the last rune from the line and the screen.
+func (p *promptLine) backspace(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.line) > 0 {
+		p.line = p.line[:len(p.line)-1]
+		fmt.Fprint(w, "\b \b")
+	}
+}
+
+// enter ends the line, moves to the next one and returns what was typed.
+func (p *promptLine) enter(w io.Writer) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active = false
+	fmt.Fprint(w, "\r\n")
+	return string(p.line)
+}
+
+// announce prints banner above the prompt and redraws it. It reports false,
+// writing nothing, if the shell isn't at its prompt.
+func (p *promptLine) announce(w io.Writer, banner string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.active {
+		return false
+	}
+	fmt.Fprintf(w, "\r\033[2K%s\r\n%s%s", banner, p.prompt, string(p.line))
+	return true
+}
+
+// announce shows msg to the visitor: above the prompt in the shell, or in
+// the status line while a full-screen program runs.
+func (s *session) announce(msg string) {
+	caps := s.caps()
+	if !s.prompt.announce(s.out, caps.highlight(">> "+caps.text(msg)+" <<")) {
+		s.statusLine(caps.text(msg))
+	}
+}
+
+// broadcast announces msg in every running session and returns how many
+// there were.
+func (r *sessionRegistry) broadcast(msg string) int {
+	r.mu.Lock()
+	all := make([]*session, 0, len(r.byID))
+	for _, s := range r.byID {
+		all = append(all, s)
+	}
+	r.mu.Unlock()
+	for _, s := range all {
+		s.announce(msg)
+	}
+	return len(all)
+}
+
+// sanitizeBroadcast turns msg into a single line without control characters,
+// so it can't move the cursor or inject escape sequences.
+func sanitizeBroadcast(msg string) string {
+	msg = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, msg)
+	return strings.Join(strings.Fields(msg), " ")
+}
+
+// adminOnly guards h with adminToken, passed as a bearer token. The endpoints
+// don't exist while no token is configured.
+func adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			http.NotFound(w, r)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// handleBroadcast announces the request body, as plain text, in every live
+// terminal:
+//
+//	curl -H "Authorization: Bearer $TOKEN" -d 'New project posted!' https://host/admin/broadcast
+func handleBroadcast(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBroadcastLen))
+	if err != nil {
+		http.Error(w, "message too long", http.StatusRequestEntityTooLarge)
+		return
+	}
+	msg := sanitizeBroadcast(string(body))
+	if msg == "" {
+		http.Error(w, "empty message", http.StatusBadRequest)
+		return
+	}
+	n := sessions.broadcast(msg)
+	slog.Info("admin: broadcast", "sessions", n, "message", msg, "remote", r.RemoteAddr)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Sessions int `json:"sessions"`
+	}{n})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// trueColor is the color depth of a terminal with 24-bit color.
+const trueColor = 1 << 24
+
+// capabilities describes what the visitor's terminal can display. Browser
+// clients announce it in their hello; for SSH, Telnet and local sessions it
+// is guessed from the environment. cli, RenderSection and the snake game
+// consult it to pick glyphs and colors, falling back to plain ASCII.
+type capabilities struct {
+	Colors   int      `json:"colors"`           // 0 (none), 8, 16, 256 or trueColor
+	Unicode  bool     `json:"unicode"`          // characters beyond ASCII: braille, bullets, blocks
+	NerdFont bool     `json:"nerdfont"`         // Nerd Font private-use glyphs
+	Mouse    bool     `json:"mouse"`            // xterm mouse reporting
+	Images   []string `json:"images,omitempty"` // inline image protocols: "sixel", "kitty", "iterm2"
+}
+
+// xtermCapabilities is assumed for browser clients whose hello doesn't say.
+var xtermCapabilities = capabilities{Colors: trueColor, Unicode: true, Mouse: true}
+
+// capabilitiesFromEnv guesses the capabilities of a terminal from its
+// environment variables, as set by the terminal emulator or sent by an SSH
+// client. Nerd Fonts can't be detected; visitors opt in with NERD_FONT=1.
+func capabilitiesFromEnv(getenv func(string) string) capabilities {
+	term := strings.ToLower(getenv("TERM"))
+	if term == "" || term == "dumb" {
+		return capabilities{}
+	}
+
+	c := capabilities{Colors: 8}
+	switch colorterm := strings.ToLower(getenv("COLORTERM")); {
+	case colorterm == "truecolor" || colorterm == "24bit" || strings.HasSuffix(term, "-direct"):
+		c.Colors = trueColor
+	case strings.Contains(term, "256color"):
+		c.Colors = 256
+	case strings.Contains(term, "16color"):
+		c.Colors = 16
+	}
+
+	// The first of these that is set decides the character set; without any,
+	// assume a UTF-8 terminal like nearly all of them are today
+	c.Unicode = true
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := strings.ToLower(getenv(name)); v != "" {
+			c.Unicode = strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+			break
+		}
+	}
+	c.NerdFont = c.Unicode && getenv("NERD_FONT") == "1"
+
+	for _, prefix := range []string{"xterm", "screen", "tmux", "rxvt", "alacritty", "foot", "wezterm"} {
+		if strings.HasPrefix(term, prefix) {
+			c.Mouse = true
+			break
+		}
+	}
+	if term == "xterm-kitty" {
+		c.Images = append(c.Images, "kitty")
+	}
+	if p := getenv("TERM_PROGRAM"); p == "iTerm.app" || p == "WezTerm" {
+		c.Images = append(c.Images, "iterm2")
+	}
+	return c
+}
+
+// String summarizes c for logs.
+func (c capabilities) String() string {
+	return fmt.Sprintf("colors=%d unicode=%t nerdfont=%t mouse=%t images=%v", c.Colors, c.Unicode, c.NerdFont, c.Mouse, c.Images)
+}
+
+// asciiReplacer maps common typographic characters to their nearest ASCII.
+var asciiReplacer = strings.NewReplacer(
+	"•", "*", "·", "*", "–", "-", "—", "-", "…", "...",
+	"‘", "'", "’", "'", "“", `"`, "”", `"`, "→", "->", "←", "<-",
+	"═", "=", "─", "-", "│", "|",
+)
+
+// toASCII returns s for a terminal without Unicode. Braille art becomes blank,
+// which keeps the layout of drawings around it; anything else without an
+// ASCII counterpart becomes '?'.
+func toASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r < utf8.RuneSelf:
+			return r
+		case r >= 0x2800 && r <= 0x28ff:
+			return ' '
+		default:
+			return '?'
+		}
+	}, asciiReplacer.Replace(s))
+}
+
+// text returns s as c can display it.
+func (c capabilities) text(s string) string {
+	if c.Unicode {
+		return s
+	}
+	return toASCII(s)
+}
+
+// highlight returns s in bold with the accent color, in the best color mode c
+// supports.
+func (c capabilities) highlight(s string) string {
+	switch {
+	case c.Colors >= trueColor:
+		return "\033[1;38;2;255;210;77m" + s + "\033[0m"
+	case c.Colors >= 256:
+		return "\033[1;38;5;221m" + s + "\033[0m"
+	case c.Colors > 0:
+		return "\033[1;33m" + s + "\033[0m"
+	default:
+		return s
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCapabilitiesFromEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want capabilities
+	}{
+		{"no TERM", nil, capabilities{}},
+		{"dumb", map[string]string{"TERM": "dumb"}, capabilities{}},
+		{"xterm", map[string]string{"TERM": "xterm"}, capabilities{Colors: 8, Unicode: true, Mouse: true}},
+		{"256 colors", map[string]string{"TERM": "xterm-256color"}, capabilities{Colors: 256, Unicode: true, Mouse: true}},
+		{"16 colors", map[string]string{"TERM": "rxvt-16color"}, capabilities{Colors: 16, Unicode: true, Mouse: true}},
+		{"COLORTERM", map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, capabilities{Colors: trueColor, Unicode: true, Mouse: true}},
+		{"direct", map[string]string{"TERM": "xterm-direct"}, capabilities{Colors: trueColor, Unicode: true, Mouse: true}},
+		{"no mouse", map[string]string{"TERM": "vt100"}, capabilities{Colors: 8, Unicode: true}},
+		{"ASCII locale", map[string]string{"TERM": "xterm", "LANG": "C"}, capabilities{Colors: 8, Mouse: true}},
+		{"UTF-8 locale", map[string]string{"TERM": "xterm", "LANG": "en_US.UTF-8"}, capabilities{Colors: 8, Unicode: true, Mouse: true}},
+		{"LC_ALL wins", map[string]string{"TERM": "xterm", "LC_ALL": "POSIX", "LANG": "en_US.UTF-8"}, capabilities{Colors: 8, Mouse: true}},
+		{"Nerd Font", map[string]string{"TERM": "xterm", "NERD_FONT": "1"}, capabilities{Colors: 8, Unicode: true, NerdFont: true, Mouse: true}},
+		{"Nerd Font needs Unicode", map[string]string{"TERM": "xterm", "LANG": "C", "NERD_FONT": "1"}, capabilities{Colors: 8, Mouse: true}},
+		{"kitty", map[string]string{"TERM": "xterm-kitty"}, capabilities{Colors: 8, Unicode: true, Mouse: true, Images: []string{"kitty"}}},
+		{"iTerm2", map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, capabilities{Colors: 256, Unicode: true, Mouse: true, Images: []string{"iterm2"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := capabilitiesFromEnv(func(name string) string { return tt.env[name] })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHelloCapabilities(t *testing.T) {
+	tests := []struct {
+		name, frame string
+		want        capabilities
+	}{
+		{"left out", `{"type":"hello","payload":{"version":1}}`, xtermCapabilities},
+		{"announced", `{"type":"hello","payload":{"version":1,"caps":{"colors":256,"unicode":false,"nerdfont":false,"mouse":true}}}`, capabilities{Colors: 256, Mouse: true}},
+		{"with images", `{"type":"hello","payload":{"version":1,"caps":{"colors":16777216,"unicode":true,"nerdfont":true,"mouse":true,"images":["sixel"]}}}`, capabilities{Colors: trueColor, Unicode: true, NerdFont: true, Mouse: true, Images: []string{"sixel"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := decodeControl([]byte(tt.frame))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var hello helloMsg
+			if err := env.decodePayload(&hello); err != nil {
+				t.Fatal(err)
+			}
+			if got := hello.capabilities(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain text", "plain text"},
+		{"• item — note…", "* item - note..."},
+		{"“quoted” ‘text’", `"quoted" 'text'`},
+		{"a → b ← c", "a -> b <- c"},
+		{"═─│", "=-|"},
+		{"⣿⠀x", "  x"},
+		{"naïve 🐍", "na?ve ?"},
+		{"\033[1mbold\033[0m", "\033[1mbold\033[0m"},
+	}
+	for _, tt := range tests {
+		if got := toASCII(tt.in); got != tt.want {
+			t.Errorf("toASCII(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+	if got := (capabilities{Unicode: true}).text("• ⣿"); got != "• ⣿" {
+		t.Errorf("text on a Unicode terminal changed %q", got)
+	}
+	if got := (capabilities{}).text("• ⣿"); got != "*  " {
+		t.Errorf("text on an ASCII terminal = %q, want %q", got, "*  ")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		colors int
+		want   string
+	}{
+		{trueColor, "38;2;"},
+		{256, "38;5;"},
+		{16, "\033[1;33m"},
+		{8, "\033[1;33m"},
+	}
+	for _, tt := range tests {
+		got := capabilities{Colors: tt.colors}.highlight("x")
+		if !strings.Contains(got, tt.want) || !strings.HasSuffix(got, "x\033[0m") {
+			t.Errorf("highlight with %d colors = %q, want %q", tt.colors, got, tt.want)
+		}
+	}
+	if got := (capabilities{}).highlight("x"); got != "x" {
+		t.Errorf("highlight without colors = %q, want plain text", got)
+	}
+}
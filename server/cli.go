@@ -2,8 +2,12 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
@@ -12,7 +16,17 @@ import (
 )
 
 var (
+	// contentDir holds the portfolio sections.
+	contentDir = "content"
+	// contentFS, if set, holds the sections instead of contentDir, e.g. the
+	// copy embedded in the WebAssembly build.
+	contentFS fs.FS
+	// typewriterDelay is the pause after each character of a section.
+	typewriterDelay = 8 * time.Millisecond
+
 	PROMPT = "[stefan.watt@portfolio]$ "
+	// MOTD is printed under SPLASH when set.
+	MOTD   = ""
 	SPLASH = `
       ////\\\\               ⠀⠀⠀⠀⠀⠀ ⢀⣠⣤⣴⣶⣶⠿⠿⠿⠿⠿⠿⢶⣶⣦⣤⣄⡀⠀⠀⠀⠀⠀⠀
       |      |                 ⠀⠀⠀⢀⣴⣾⠿⠛⠉⠁⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠀⠉⠛⠿⣷⣦⡀⠀⠀⠀
@@ -43,11 +57,29 @@ var (
 
 // typewriterWriter writes output with a small delay between characters.
 // It passes ANSI escape sequences through without delay and writes newlines immediately.
+// Once ctx is done it stops typing and returns ctx.Err(), so an ended session
+// doesn't keep rendering into a pump nobody reads.
 type typewriterWriter struct {
+	ctx   context.Context
 	w     io.Writer
 	delay time.Duration
 }
 
+// pause waits for the delay between characters, or until ctx is done.
+func (tw *typewriterWriter) pause() error {
+	if err := tw.ctx.Err(); err != nil {
+		return err
+	}
+	t := time.NewTimer(tw.delay)
+	defer t.Stop()
+	select {
+	case <-tw.ctx.Done():
+		return tw.ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
 func (tw *typewriterWriter) Write(p []byte) (int, error) {
 	written := 0
 	for i := 0; i < len(p); {
@@ -90,16 +122,33 @@ func (tw *typewriterWriter) Write(p []byte) (int, error) {
 		written += size
 
 		if r != '\n' && r != '\r' && r != '\t' && r != ' ' {
-			time.Sleep(tw.delay)
+			if err := tw.pause(); err != nil {
+				return written, err
+			}
 		}
 		i += size
 	}
 	return written, nil
 }
 
-func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
+// contentFiles returns the filesystem the portfolio sections are loaded from.
+func contentFiles() fs.FS {
+	if contentFS != nil {
+		return contentFS
+	}
+	return os.DirFS(contentDir)
+}
+
+// builtinCommands are the commands cli handles besides portfolio sections.
+var builtinCommands = map[string]struct{}{
+	"help": {}, "credit": {}, "?": {}, "clear": {}, "replay": {}, "share": {}, "quit": {}, "exit": {},
+}
+
+func cli(s *session) {
+	in, out, logger := s.in, s.out, s.logger
+
 	// Initialize portfolio manager
-	pm, err := NewPortfolioManager("content")
+	pm, err := NewPortfolioManagerFS(contentFiles())
 	if err != nil {
 		logger.LogError("Could not load portfolio content: " + err.Error())
 		fmt.Fprintf(out, "Warning: Could not load portfolio content: %v\n", err)
@@ -110,14 +159,22 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 		logger.LogDebug(fmt.Sprintf("Loaded %d portfolio sections: %v", len(commands), commands))
 	}
 
-	fmt.Fprintln(out, SPLASH)
+	caps := s.caps()
+	fmt.Fprintln(out, caps.text(SPLASH))
+	if MOTD != "" {
+		fmt.Fprintln(out, caps.text(MOTD))
+		fmt.Fprintln(out)
+	}
 	reader := bufio.NewReader(in)
 
 	for {
-		fmt.Fprint(out, PROMPT)
-		var lineRunes []rune
+		s.prompt.show(out, PROMPT)
 		for {
 			r, size, err := reader.ReadRune()
+			if errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) {
+				logger.LogDebug("Input closed")
+				return
+			}
 			if err != nil {
 				logger.LogError("Error reading input: " + err.Error())
 				fmt.Fprintln(out, "error:", err)
@@ -127,11 +184,29 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 			// Normalize CR to LF
 			if r == '\n' || r == '\r' {
 				// Treat CR or LF as newline; if CR, emit CRLF for proper line break
-				fmt.Fprint(out, "\r\n")
-				line := string(lineRunes)
+				line := s.prompt.enter(out)
 				line = strings.TrimSpace(line)
+				fields := strings.Fields(line)
+				var args []string
+				if len(fields) > 1 {
+					args = fields[1:]
+				}
 
 				logger.LogDebug("Command received: '" + line + "'")
+				if len(fields) > 0 {
+					// What visitors type otherwise stays out of the journal
+					command := ""
+					if _, ok := builtinCommands[fields[0]]; ok {
+						command = fields[0]
+						metrics.commands.inc(command)
+					} else if _, ok := pm.GetSection(line); ok {
+						command = line
+						metrics.commands.inc(command)
+					} else {
+						metrics.unknownCommands.inc()
+					}
+					s.record(journalEvent{Event: eventCommand, Command: command})
+				}
 
 				switch line {
 				case "quit", "exit":
@@ -142,7 +217,30 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 					return
 				case "?":
 					logger.LogInfo("Starting snake game")
-					snake.StartGame()
+					game := snake.NewGame(in, out)
+					caps := s.caps()
+					game.Display = snake.Display{Colors: caps.Colors > 0, Unicode: caps.Unicode, NerdFont: caps.NerdFont}
+					played, rounds, best := time.Now(), 0, 0
+					game.OnGameover = func(score int) {
+						metrics.snakeScores.observe(float64(score))
+						rounds, best = rounds+1, max(best, score)
+					}
+					metrics.snakeGames.inc()
+					stopResize := s.size.forward(func(sz termSize) { game.Resize(sz.Cols, sz.Rows) })
+					// A round in progress is saved if the server shuts down under it
+					s.setOnStop(func() {
+						if err := game.SaveScore(); err != nil {
+							logger.LogError("Could not save snake score: " + err.Error())
+						}
+					})
+					game.Start()
+					s.setOnStop(nil)
+					stopResize()
+					s.record(journalEvent{Event: eventGame, Rounds: rounds, Score: best, Duration: time.Since(played).Seconds()})
+				case "replay":
+					fmt.Fprintln(out, "usage: replay <id> [speed]")
+				case "share":
+					fmt.Fprintf(out, "Others can watch this session (read-only) at /?watch=%s\n", s.id)
 				case "clear":
 					logger.LogDebug("Clearing screen")
 					fmt.Fprint(out, "\033[H\033[2J")
@@ -152,8 +250,14 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 					fmt.Fprintln(out, "Available commands:")
 					fmt.Fprintln(out, "  help      Show this help message")
 					fmt.Fprintln(out, "  credit    Start the Bubble Tea credit card example")
-					fmt.Fprintln(out, "  ?         󰪰 A little easter egg. What could it be?")
+					if s.caps().NerdFont {
+						fmt.Fprintln(out, "  ?         󰪰 A little easter egg. What could it be?")
+					} else {
+						fmt.Fprintln(out, "  ?         A little easter egg. What could it be?")
+					}
 					fmt.Fprintln(out, "  clear     Clear the terminal")
+					fmt.Fprintln(out, "  replay    Replay a recorded session: replay <id> [speed]")
+					fmt.Fprintln(out, "  share     Show a link that lets others watch this session")
 					fmt.Fprintln(out, "  quit      Exit the application")
 
 					// Add dynamic portfolio section commands
@@ -163,19 +267,45 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 						fmt.Fprintln(out, "Portfolio sections:")
 						for _, cmd := range commands {
 							section, _ := pm.GetSection(cmd)
-							fmt.Fprintf(out, "  %-8s %s\n", cmd, section.Title)
+							fmt.Fprintf(out, "  %-8s %s\n", cmd, s.caps().text(section.Title))
 						}
 					}
 				default:
+					if len(fields) > 0 && fields[0] == "replay" {
+						id, speed, err := parseReplayArgs(args)
+						var rec *os.File
+						if err == nil {
+							rec, err = openRecording(id)
+						}
+						if err != nil {
+							fmt.Fprintln(out, err)
+							break
+						}
+						logger.LogInfo("Replaying recording " + id)
+						fmt.Fprint(out, "\033[H\033[2J")
+						err = replay(s.ctx, s.in, out, rec, speed)
+						rec.Close()
+						fmt.Fprint(out, "\033[?1049l\r\n[replay finished]\r\n")
+						if err != nil {
+							fmt.Fprintln(out, err)
+						}
+						break
+					}
 					// Check if it's a portfolio section command
 					logger.LogInfo("Trying to render portfolio section: " + line)
 					if section, exists := pm.GetSection(line); exists {
 						logger.LogInfo("Rendering portfolio section: " + line)
 						// Typewriter effect only for section rendering
-						tw := &typewriterWriter{w: out, delay: 8 * time.Millisecond}
-						pm.RenderSection(tw, section)
+						tw := &typewriterWriter{ctx: s.ctx, w: out, delay: typewriterDelay}
+						width := 0
+						if sz, ok := s.size.get(); ok {
+							width = sz.Cols
+						}
+						viewed := time.Now()
+						pm.RenderSection(tw, section, width, s.caps())
 						// Wait for user input to return to main menu
 						reader.ReadString('\n')
+						s.record(journalEvent{Event: eventSection, Section: line, Duration: time.Since(viewed).Seconds()})
 						fmt.Fprint(out, "\033[H\033[2J") // Clear screen
 					} else if line != "" {
 						logger.LogDebug("Unknown command: " + line)
@@ -199,8 +329,6 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 					}
 				}
 
-				// Reset for next prompt
-				lineRunes = nil
 				break
 			}
 
@@ -208,15 +336,11 @@ func cli(in io.Reader, out io.Writer, logger *ConsoleLogger) {
 				continue
 			}
 			if r == 0x7f || r == '\b' {
-				if len(lineRunes) > 0 {
-					lineRunes = lineRunes[:len(lineRunes)-1]
-					fmt.Fprint(out, "\b \b")
-				}
+				s.prompt.backspace(out)
 				continue
 			}
 			if r >= 0x20 && r != 0x7f {
-				lineRunes = append(lineRunes, r)
-				fmt.Fprint(out, string(r))
+				s.prompt.insert(out, r)
 			}
 		}
 	}
@@ -0,0 +1,357 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	snake "tui-portfolio/server/snake"
+)
+
+// Config is the server configuration. Values come from the defaults below,
+// then the JSON config file, then PORTFOLIO_* environment variables, then
+// command-line flags, each overriding the one before.
+type Config struct {
+	Addr            string   `json:"addr"`
+	ContentDir      string   `json:"content_dir"`
+	WebDir          string   `json:"web_dir"`
+	Prompt          string   `json:"prompt"`
+	MOTD            string   `json:"motd"`
+	TypewriterDelay duration `json:"typewriter_delay"`
+	OutputTick      duration `json:"output_tick"`
+	SnakeFPS        float64  `json:"snake_fps"`
+	SnakeSpeed      int      `json:"snake_speed"`
+	SnakeAssetDir   string   `json:"snake_asset_dir"`
+
+	TLSCert      string `json:"tls_cert"`
+	TLSKey       string `json:"tls_key"`
+	SelfSigned   bool   `json:"self_signed"`
+	RedirectAddr string `json:"redirect_addr"`
+
+	RecordDir       string   `json:"record_dir"`
+	RecordMaxBytes  int64    `json:"record_max_bytes"`
+	RecordRetention duration `json:"record_retention"`
+	SSHAddr         string   `json:"ssh_addr"`
+	SSHHostKey      string   `json:"ssh_host_key"`
+	TelnetAddr      string   `json:"telnet_addr"`
+	Local           bool     `json:"local"`
+	AdminToken      string   `json:"admin_token"`
+	Journal         string   `json:"journal"`
+
+	AllowedOrigins     stringList `json:"allowed_origins"`
+	MaxSessions        int        `json:"max_sessions"`
+	MaxSessionsPerIP   int        `json:"max_sessions_per_ip"`
+	TrustedProxies     stringList `json:"trusted_proxies"`
+	IdleTimeout        duration   `json:"idle_timeout"`
+	IdleWarning        duration   `json:"idle_warning"`
+	MaxSessionDuration duration   `json:"max_session_duration"`
+	ResumeGrace        duration   `json:"resume_grace"`
+	PingInterval       duration   `json:"ping_interval"`
+	PongWait           duration   `json:"pong_wait"`
+	ShutdownTimeout    duration   `json:"shutdown_timeout"`
+
+	LogFormat    string     `json:"log_format"`
+	LogLevel     slog.Level `json:"log_level"`
+	ConsoleLevel slog.Level `json:"console_level"`
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Addr:            ":8080",
+		ContentDir:      "content",
+		Prompt:          "[stefan.watt@portfolio]$ ",
+		TypewriterDelay: duration(8 * time.Millisecond),
+		OutputTick:      duration(40 * time.Millisecond),
+		SnakeFPS:        60,
+		SnakeSpeed:      8,
+
+		RecordMaxBytes:  16 << 20,
+		RecordRetention: duration(7 * 24 * time.Hour),
+		SSHHostKey:      "ssh_host_ed25519_key",
+
+		AllowedOrigins:     stringList{"http://localhost:5173"},
+		MaxSessions:        200,
+		MaxSessionsPerIP:   10,
+		IdleTimeout:        duration(15 * time.Minute),
+		IdleWarning:        duration(time.Minute),
+		MaxSessionDuration: duration(2 * time.Hour),
+		ResumeGrace:        duration(2 * time.Minute),
+		PingInterval:       duration(30 * time.Second),
+		PongWait:           duration(75 * time.Second),
+		ShutdownTimeout:    duration(10 * time.Second),
+
+		LogFormat:    "text",
+		LogLevel:     slog.LevelInfo,
+		ConsoleLevel: slog.LevelInfo,
+	}
+}
+
+// flagSet binds a flag to every field of c. Each flag can also be set with
+// the environment variable named by envName.
+func (c *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&c.Addr, "addr", c.Addr, "HTTP listen address")
+	fs.StringVar(&c.ContentDir, "content-dir", c.ContentDir, "directory with the portfolio sections")
+	fs.StringVar(&c.WebDir, "web-dir", c.WebDir, "serve the frontend build from this directory instead of the embedded one")
+	fs.StringVar(&c.Prompt, "prompt", c.Prompt, "shell prompt")
+	fs.StringVar(&c.MOTD, "motd", c.MOTD, "message of the day, shown under the splash screen")
+	fs.TextVar(&c.TypewriterDelay, "typewriter-delay", &c.TypewriterDelay, "delay per character when rendering sections")
+	fs.TextVar(&c.OutputTick, "output-tick", &c.OutputTick, "how long terminal output is batched before it is sent")
+	fs.Float64Var(&c.SnakeFPS, "snake-fps", c.SnakeFPS, "snake frame rate")
+	fs.IntVar(&c.SnakeSpeed, "snake-speed", c.SnakeSpeed, "snake speed (moves every fps/speed frames)")
+	fs.StringVar(&c.SnakeAssetDir, "snake-asset-dir", c.SnakeAssetDir, "directory with art files replacing the snake game's built-in ones")
+
+	fs.StringVar(&c.TLSCert, "tls-cert", c.TLSCert, "serve HTTPS with this certificate file (reloaded when it changes)")
+	fs.StringVar(&c.TLSKey, "tls-key", c.TLSKey, "private key file for --tls-cert")
+	fs.BoolVar(&c.SelfSigned, "self-signed", c.SelfSigned, "serve HTTPS with a certificate for localhost generated at startup")
+	fs.StringVar(&c.RedirectAddr, "redirect-addr", c.RedirectAddr, "redirect plain HTTP on this address to HTTPS, e.g. :80 (disabled if empty)")
+
+	fs.StringVar(&c.RecordDir, "record-dir", c.RecordDir, "record sessions as asciicast v2 files in this directory (disabled if empty)")
+	fs.Int64Var(&c.RecordMaxBytes, "record-max-bytes", c.RecordMaxBytes, "stop recording a session once its file reaches this size (0 for no limit)")
+	fs.TextVar(&c.RecordRetention, "record-retention", &c.RecordRetention, "delete recordings older than this (0 to keep them)")
+	fs.StringVar(&c.SSHAddr, "ssh-addr", c.SSHAddr, "serve the shell over SSH on this address, e.g. :2222 (disabled if empty)")
+	fs.StringVar(&c.SSHHostKey, "ssh-host-key", c.SSHHostKey, "SSH host key file, generated if missing")
+	fs.StringVar(&c.TelnetAddr, "telnet-addr", c.TelnetAddr, "serve the shell over Telnet on this address, e.g. :2323 (disabled if empty)")
+	fs.BoolVar(&c.Local, "local", c.Local, "run one session on this terminal instead of serving")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "bearer token for the /admin endpoints (disabled if empty)")
+	fs.StringVar(&c.Journal, "journal", c.Journal, "append session events to this JSONL file, for the stats command (disabled if empty)")
+
+	fs.Var(&c.AllowedOrigins, "allowed-origins", "comma-separated origins allowed to open /ws besides the server's own, or * for any")
+	fs.IntVar(&c.MaxSessions, "max-sessions", c.MaxSessions, "maximum concurrent sessions (0 for unlimited)")
+	fs.IntVar(&c.MaxSessionsPerIP, "max-sessions-per-ip", c.MaxSessionsPerIP, "maximum concurrent sessions per client IP (0 for unlimited); behind a reverse proxy, set --trusted-proxies or all visitors share the proxy's limit")
+	fs.Var(&c.TrustedProxies, "trusted-proxies", "comma-separated addresses or CIDR ranges of reverse proxies whose X-Forwarded-For header gives the client IP")
+	fs.TextVar(&c.IdleTimeout, "idle-timeout", &c.IdleTimeout, "disconnect sessions without input for this long (0 to disable)")
+	fs.TextVar(&c.IdleWarning, "idle-warning", &c.IdleWarning, "show a countdown this long before the idle timeout")
+	fs.TextVar(&c.MaxSessionDuration, "max-session-duration", &c.MaxSessionDuration, "disconnect every session after this long (0 to disable)")
+	fs.TextVar(&c.ResumeGrace, "resume-grace", &c.ResumeGrace, "keep sessions of dropped WebSocket clients this long for a reconnect (0 to disable)")
+	fs.TextVar(&c.PingInterval, "ping-interval", &c.PingInterval, "WebSocket keepalive ping interval")
+	fs.TextVar(&c.PongWait, "pong-wait", &c.PongWait, "drop WebSocket clients silent for this long")
+	fs.TextVar(&c.ShutdownTimeout, "shutdown-timeout", &c.ShutdownTimeout, "how long to wait for sessions to end on shutdown")
+
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "server log format: text or json")
+	fs.TextVar(&c.LogLevel, "log-level", &c.LogLevel, "lowest level written to the server log (debug, info, warn, error)")
+	fs.TextVar(&c.ConsoleLevel, "console-level", &c.ConsoleLevel, "lowest level forwarded to the browser console")
+	return fs
+}
+
+// envName returns the environment variable for a flag, e.g. PORTFOLIO_SSH_ADDR.
+func envName(flagName string) string {
+	return "PORTFOLIO_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// loadConfig builds the configuration from args (without the program name).
+// It also reports whether --print-config was given.
+func loadConfig(args []string) (cfg *Config, printConfig bool, err error) {
+	// --config and --print-config are read first; the remaining flags are
+	// parsed again at the end so they override the file and the environment
+	var path string
+	pre := defaultConfig().flagSet()
+	pre.SetOutput(io.Discard)
+	pre.StringVar(&path, "config", os.Getenv("PORTFOLIO_CONFIG"), "JSON config file")
+	pre.Bool("print-config", false, "")
+	if err := pre.Parse(args); err != nil {
+		// Let the real parse below report the error (or print help)
+		path = ""
+	}
+
+	cfg = defaultConfig()
+	if printConfig, err = cfg.load(path, args); err != nil {
+		return nil, false, err
+	}
+	if cfg.Local {
+		// The only session on this terminal holds nobody else's slot, so
+		// by default it never times out. Load again on top of these
+		// defaults, so timeouts set explicitly still apply.
+		cfg = defaultConfig()
+		cfg.IdleTimeout, cfg.MaxSessionDuration = 0, 0
+		if printConfig, err = cfg.load(path, args); err != nil {
+			return nil, false, err
+		}
+	}
+	return cfg, printConfig, cfg.validate()
+}
+
+// load applies the config file at path (if any), the environment and args
+// on top of c.
+func (c *Config) load(path string, args []string) (printConfig bool, err error) {
+	if path != "" {
+		if err := c.readFile(path); err != nil {
+			return false, err
+		}
+	}
+
+	fs := c.flagSet()
+	fs.String("config", path, "JSON config file (env PORTFOLIO_CONFIG)")
+	printFlag := fs.Bool("print-config", false, "print the effective configuration and exit")
+	var envErrs []error
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" || f.Name == "print-config" {
+			return
+		}
+		if v, ok := os.LookupEnv(envName(f.Name)); ok {
+			if err := fs.Set(f.Name, v); err != nil {
+				envErrs = append(envErrs, fmt.Errorf("%s: %w", envName(f.Name), err))
+			}
+		}
+	})
+	if err := errors.Join(envErrs...); err != nil {
+		return false, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return false, err
+	}
+	if fs.NArg() > 0 {
+		return false, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return *printFlag, nil
+}
+
+func (c *Config) readFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate reports every invalid value at once.
+func (c *Config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	check(c.Addr != "", "addr must not be empty")
+	if fi, err := os.Stat(c.ContentDir); err != nil || !fi.IsDir() {
+		check(false, "content_dir %q is not a directory", c.ContentDir)
+	}
+	if c.WebDir != "" {
+		if fi, err := os.Stat(c.WebDir); err != nil || !fi.IsDir() {
+			check(false, "web_dir %q is not a directory", c.WebDir)
+		}
+	}
+	check(c.Prompt != "", "prompt must not be empty")
+	check(c.TypewriterDelay >= 0, "typewriter_delay must not be negative")
+	check(c.OutputTick > 0 && c.OutputTick <= duration(time.Second), "output_tick must be between 0 and 1s")
+	check(c.SnakeFPS > 0 && c.SnakeFPS <= 240, "snake_fps must be between 0 and 240")
+	check(c.SnakeSpeed > 0, "snake_speed must be positive")
+	check((c.TLSCert == "") == (c.TLSKey == ""), "tls_cert and tls_key must be given together")
+	check(!c.SelfSigned || c.TLSCert == "", "self_signed and tls_cert are mutually exclusive")
+	check(c.RedirectAddr == "" || c.SelfSigned || c.TLSCert != "", "redirect_addr needs TLS")
+	check(c.RecordMaxBytes >= 0, "record_max_bytes must not be negative")
+	check(c.RecordRetention >= 0, "record_retention must not be negative")
+	check(c.SSHAddr == "" || c.SSHHostKey != "", "ssh_host_key is required with ssh_addr")
+	check(c.AdminToken == "" || len(c.AdminToken) >= 16, "admin_token must be at least 16 characters")
+	check(c.MaxSessions >= 0, "max_sessions must not be negative")
+	check(c.MaxSessionsPerIP >= 0, "max_sessions_per_ip must not be negative")
+	if _, err := parseProxies(c.TrustedProxies); err != nil {
+		check(false, "trusted_proxies: %v", err)
+	}
+	check(c.IdleTimeout >= 0, "idle_timeout must not be negative")
+	check(c.IdleWarning >= 0 && (c.IdleTimeout == 0 || c.IdleWarning < c.IdleTimeout), "idle_warning must be shorter than idle_timeout")
+	check(c.MaxSessionDuration >= 0, "max_session_duration must not be negative")
+	check(c.ResumeGrace >= 0, "resume_grace must not be negative")
+	check(c.PingInterval > 0, "ping_interval must be positive")
+	check(c.PongWait > c.PingInterval, "pong_wait must be longer than ping_interval")
+	check(c.ShutdownTimeout > 0, "shutdown_timeout must be positive")
+	check(c.LogFormat == "text" || c.LogFormat == "json", "log_format must be text or json")
+	return errors.Join(errs...)
+}
+
+// apply hands the configuration to the parts of the server that use it.
+func (c *Config) apply() {
+	contentDir = c.ContentDir
+	webDir = c.WebDir
+	PROMPT = c.Prompt
+	MOTD = c.MOTD
+	typewriterDelay = time.Duration(c.TypewriterDelay)
+	outputBatchInterval = time.Duration(c.OutputTick)
+	snake.DEFAULT_FPS = c.SnakeFPS
+	snake.DEFAULT_SPEED = c.SnakeSpeed
+	snake.AssetDir = c.SnakeAssetDir
+
+	recordingsDir = c.RecordDir
+	maxRecordingBytes = c.RecordMaxBytes
+	recordingRetention = time.Duration(c.RecordRetention)
+	sshAddr = c.SSHAddr
+	sshHostKeyPath = c.SSHHostKey
+	telnetAddr = c.TelnetAddr
+	localMode = c.Local
+	adminToken = c.AdminToken
+	journalPath = c.Journal
+
+	admission = newAdmissionPolicy(c.AllowedOrigins, c.MaxSessions, c.MaxSessionsPerIP)
+	trustedProxies, _ = parseProxies(c.TrustedProxies)
+	idleTimeout = time.Duration(c.IdleTimeout)
+	idleWarning = time.Duration(c.IdleWarning)
+	maxSessionDuration = time.Duration(c.MaxSessionDuration)
+	resumeGrace = time.Duration(c.ResumeGrace)
+	pingInterval = time.Duration(c.PingInterval)
+	pongWait = time.Duration(c.PongWait)
+
+	logLevel.Set(c.LogLevel)
+	consoleLevel.Set(c.ConsoleLevel)
+}
+
+// print writes the configuration as JSON, in the format readFile accepts.
+// The admin token is masked.
+func (c *Config) print(w io.Writer) error {
+	shown := *c
+	if shown.AdminToken != "" {
+		shown.AdminToken = "********"
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&shown)
+}
+
+// duration is a time.Duration written as "8ms" in JSON and flags.
+type duration time.Duration
+
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// stringList is a list of strings given as a comma-separated flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = nil
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
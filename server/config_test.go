@@ -0,0 +1,151 @@
+//go:build !js
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file with content_dir pointing at a fresh
+// directory, as validate requires, followed by the given JSON fields.
+func writeConfig(t *testing.T, fields string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	content := `{"content_dir": "` + filepath.ToSlash(dir) + `"` + fields + "}"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	path := writeConfig(t, `,
+		"snake_speed": 3,
+		"max_sessions_per_ip": 5,
+		"max_sessions": 50,
+		"idle_timeout": "20m"`)
+	t.Setenv(envName("max-sessions-per-ip"), "6")
+	t.Setenv(envName("max-sessions"), "60")
+	t.Setenv(envName("idle-timeout"), "25m")
+
+	cfg, printConfig, err := loadConfig([]string{"-config", path, "-max-sessions", "70", "-idle-timeout=30m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if printConfig {
+		t.Error("printConfig set without --print-config")
+	}
+	tests := []struct {
+		name      string
+		got, want any
+	}{
+		{"default", cfg.Addr, ":8080"},
+		{"file over default", cfg.SnakeSpeed, 3},
+		{"env over file", cfg.MaxSessionsPerIP, 6},
+		{"flag over env", cfg.MaxSessions, 70},
+		{"flag over env (duration)", cfg.IdleTimeout, duration(30 * time.Minute)},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, fields string
+		args         []string
+		env          map[string]string
+		want         string
+	}{
+		{name: "unknown field", fields: `, "max_session": 5`, want: `unknown field "max_session"`},
+		{name: "wrong type", fields: `, "max_sessions": "5"`, want: "max_sessions"},
+		{name: "bad env", env: map[string]string{"max-sessions": "many"}, want: envName("max-sessions")},
+		{name: "invalid value", args: []string{"-snake-fps", "0"}, want: "snake_fps"},
+		{name: "extra argument", args: []string{"serve"}, want: "unexpected arguments"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, v := range tt.env {
+				t.Setenv(envName(name), v)
+			}
+			args := append([]string{"-config", writeConfig(t, tt.fields)}, tt.args...)
+			_, _, err := loadConfig(args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %v, want one mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigPrintRoundTrip(t *testing.T) {
+	path := writeConfig(t, `, "admin_token": "0123456789abcdef"`)
+	cfg, printConfig, err := loadConfig([]string{"-config", path, "-print-config", "-allowed-origins", "https://a.example,https://b.example", "-log-level", "debug"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !printConfig {
+		t.Error("printConfig not set with --print-config")
+	}
+
+	var buf bytes.Buffer
+	if err := cfg.print(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), cfg.AdminToken) {
+		t.Error("printed config shows the admin token")
+	}
+	printed := filepath.Join(t.TempDir(), "printed.json")
+	if err := os.WriteFile(printed, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var read Config
+	if err := read.readFile(printed); err != nil {
+		t.Fatalf("printed config doesn't read back: %v", err)
+	}
+
+	want := *cfg
+	want.AdminToken = "********"
+	if !reflect.DeepEqual(&read, &want) {
+		t.Errorf("read back %+v, want %+v", read, want)
+	}
+}
+
+func TestLoadConfigLocalTimeouts(t *testing.T) {
+	tests := []struct {
+		name                 string
+		fields               string
+		args                 []string
+		env                  map[string]string
+		wantIdle, wantMaxDur duration
+	}{
+		{name: "serving", wantIdle: duration(15 * time.Minute), wantMaxDur: duration(2 * time.Hour)},
+		{name: "local", args: []string{"-local"}},
+		{name: "local from file", fields: `, "local": true`},
+		{name: "local from env", env: map[string]string{"local": "true"}},
+		{name: "local with flag", args: []string{"-local", "-idle-timeout", "5m"}, wantIdle: duration(5 * time.Minute)},
+		{name: "local with file", fields: `, "max_session_duration": "1h"`, args: []string{"-local"}, wantMaxDur: duration(time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, v := range tt.env {
+				t.Setenv(envName(name), v)
+			}
+			cfg, _, err := loadConfig(append([]string{"-config", writeConfig(t, tt.fields)}, tt.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.IdleTimeout != tt.wantIdle || cfg.MaxSessionDuration != tt.wantMaxDur {
+				t.Errorf("got idle_timeout %v, max_session_duration %v; want %v, %v", cfg.IdleTimeout, cfg.MaxSessionDuration, tt.wantIdle, tt.wantMaxDur)
+			}
+		})
+	}
+}
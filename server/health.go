@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"tui-portfolio/server/snake"
+)
+
+// Build information, set at link time:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD)"
+//
+// Without them, commit falls back to the VCS revision Go stamps into the binary.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+// versionInfo is the body of /version.
+type versionInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit"`
+	Go      string `json:"go"`
+}
+
+func buildVersion() versionInfo {
+	v := versionInfo{Version: version, Commit: commit, Go: runtime.Version()}
+	if v.Commit == "" {
+		v.Commit = "unknown"
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, s := range info.Settings {
+				if s.Key == "vcs.revision" {
+					v.Commit = s.Value
+				}
+			}
+		}
+	}
+	return v
+}
+
+// handleHealthz reports that the process is up and serving HTTP.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// handleReadyz reports whether new sessions would work: the portfolio
+// content has at least one section and the snake asset overrides, if
+// configured, are there. It answers 503 listing the failed checks otherwise.
+func handleReadyz(w http.ResponseWriter, r *http.Request) {
+	var problems []string
+	if pm, err := NewPortfolioManagerFS(contentFiles()); err != nil {
+		problems = append(problems, "content: "+err.Error())
+	} else if len(pm.GetAllCommands()) == 0 {
+		problems = append(problems, fmt.Sprintf("content: no sections in %s", contentDir))
+	}
+	if missing := snake.MissingAssets(); len(missing) > 0 {
+		problems = append(problems, "snake: missing "+strings.Join(missing, ", "))
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if len(problems) > 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		for _, p := range problems {
+			fmt.Fprintln(w, p)
+		}
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// handleVersion reports the build version, commit and Go version as JSON.
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildVersion())
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// journalPath is the append-only JSONL file session events are written to.
+// Empty disables the journal.
+var journalPath string
+
+// journal is the open journal, or nil while disabled.
+var journal *eventJournal
+
+// Journal event types.
+const (
+	eventConnect    = "connect"    // session started
+	eventCommand    = "command"    // shell command run
+	eventSection    = "section"    // portfolio section viewed, with the time spent on it
+	eventGame       = "game"       // snake played, with rounds and best score
+	eventDisconnect = "disconnect" // session ended, with its length
+)
+
+// journalEvent is one line of the journal. Durations are in seconds.
+type journalEvent struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Event    string    `json:"event"`
+	Visitor  string    `json:"visitor,omitempty"`  // connect: pseudonym of the client IP
+	Frontend string    `json:"frontend,omitempty"` // connect: ws, sse, ssh, telnet or local
+	Command  string    `json:"command,omitempty"`  // command: builtin or section name, "" if unknown
+	Section  string    `json:"section,omitempty"`
+	Rounds   int       `json:"rounds,omitempty"`
+	Score    int       `json:"score,omitempty"`
+	Duration float64   `json:"duration,omitempty"`
+	Reason   string    `json:"reason,omitempty"` // disconnect: why the server ended it
+}
+
+// eventJournal appends events to the journal file. A nil *eventJournal
+// drops them, so callers don't check whether the journal is enabled.
+type eventJournal struct {
+	mu   sync.Mutex
+	f    *os.File
+	salt []byte // keys the visitor pseudonyms
+}
+
+// openJournal opens the journal at path for appending. Visitors are
+// identified by an HMAC of their IP keyed with a salt kept next to the
+// journal in path+".salt", generated on first start, so the journal itself
+// holds no addresses but the same visitor is recognized across sessions.
+func openJournal(path string) (*eventJournal, error) {
+	salt, err := loadJournalSalt(path + ".salt")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return &eventJournal{f: f, salt: salt}, nil
+}
+
+func loadJournalSalt(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		salt := make([]byte, 16)
+		_, _ = rand.Read(salt)
+		data = []byte(hex.EncodeToString(salt))
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		slog.Info("journal: generated salt", "path", path)
+	} else if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// visitor returns the pseudonym recorded for a client IP.
+func (j *eventJournal) visitor(ip string) string {
+	if j == nil {
+		return ""
+	}
+	mac := hmac.New(sha256.New, j.salt)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
+// record appends ev, stamping it with the current time. Failures are logged;
+// the journal never gets in the way of a session.
+func (j *eventJournal) record(ev journalEvent) {
+	if j == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	line, err := json.Marshal(ev)
+	if err != nil {
+		slog.Error("journal: encode failed", "err", err)
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// One write per line, so a crash leaves at most a truncated last line
+	if _, err := j.f.Write(append(line, '\n')); err != nil {
+		slog.Error("journal: write failed", "err", err)
+	}
+}
+
+func (j *eventJournal) Close() error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// record writes ev for the session to the journal.
+func (s *session) record(ev journalEvent) {
+	ev.Session = s.id
+	journal.record(ev)
+}
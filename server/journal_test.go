@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	visitor := j.visitor("192.0.2.1")
+	j.record(journalEvent{Session: "s1", Event: eventConnect, Visitor: visitor, Frontend: "ws"})
+	j.record(journalEvent{Session: "s1", Event: eventSection, Section: "about", Duration: 12.5})
+	if err := j.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "192.0.2.1") {
+		t.Error("the journal contains the client IP")
+	}
+	var events []journalEvent
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for sc.Scan() {
+		var ev journalEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil {
+			t.Fatalf("invalid line %q: %v", sc.Text(), err)
+		}
+		events = append(events, ev)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if events[0].Visitor != visitor || events[0].Frontend != "ws" || events[0].Time.IsZero() {
+		t.Errorf("connect event = %+v", events[0])
+	}
+	if events[1].Section != "about" || events[1].Duration != 12.5 {
+		t.Errorf("section event = %+v", events[1])
+	}
+
+	// Reopening appends, and the salt kept next to the journal keeps
+	// visitors recognizable
+	j, err = openJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+	if j.visitor("192.0.2.1") != visitor {
+		t.Error("the same IP got another pseudonym after reopening")
+	}
+	if j.visitor("192.0.2.2") == visitor {
+		t.Error("different IPs got the same pseudonym")
+	}
+	j.record(journalEvent{Session: "s1", Event: eventDisconnect})
+	if data, _ := os.ReadFile(path); strings.Count(string(data), "\n") != 3 {
+		t.Errorf("reopened journal didn't append: %q", data)
+	}
+}
+
+func TestJournalSaltPerJournal(t *testing.T) {
+	dir := t.TempDir()
+	a, err := openJournal(filepath.Join(dir, "a.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := openJournal(filepath.Join(dir, "b.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+	if a.visitor("192.0.2.1") == b.visitor("192.0.2.1") {
+		t.Error("journals with their own salt gave the same pseudonym")
+	}
+}
+
+func TestJournalDisabled(t *testing.T) {
+	var j *eventJournal
+	if v := j.visitor("192.0.2.1"); v != "" {
+		t.Errorf("disabled journal returned pseudonym %q", v)
+	}
+	j.record(journalEvent{Session: "s1", Event: eventConnect})
+	if err := j.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build !js
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/charmbracelet/x/term"
+)
+
+// localMode runs a single session on the host terminal instead of serving.
+var localMode bool
+
+// runLocal runs cli(), the snake game and the Bubble Tea model directly on
+// stdin/stdout. The terminal is put in raw mode for the duration and SIGWINCH
+// drives resizes, the way the SSH and WebSocket frontends' resize messages do.
+func runLocal() error {
+	in, out := os.Stdin.Fd(), os.Stdout.Fd()
+	if !term.IsTerminal(in) || !term.IsTerminal(out) {
+		return errors.New("--local needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(in, state)
+
+	// Raw mode turns off output post-processing, so expand LF ourselves
+	sess := newSession(context.Background(), lfToCRLF(func(b []byte) error {
+		_, err := os.Stdout.Write(b)
+		return err
+	}))
+
+	sess.setCaps(capabilitiesFromEnv(os.Getenv))
+	sess.visitor, sess.frontend = journal.visitor("local"), "local"
+
+	updateSize := func() {
+		if w, h, err := term.GetSize(out); err == nil {
+			sess.size.set(w, h)
+		}
+	}
+	updateSize()
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for {
+			select {
+			case <-sess.ctx.Done():
+				return
+			case <-winch:
+				updateSize()
+			}
+		}
+	}()
+
+	// Reader pump → keystrokes
+	go func() {
+		defer sess.close()
+		buf := make([]byte, 1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				sess.input(buf[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	sess.run()
+	if reason := sess.reason(); reason != "" {
+		os.Stdout.WriteString("\r\n[" + reason + "]\r\n")
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// logLevel is the lowest level written to the server log.
+	logLevel = new(slog.LevelVar)
+	// consoleLevel is the lowest level forwarded to the visitor's browser console.
+	consoleLevel = new(slog.LevelVar)
+)
+
+// setupLogging makes the default slog logger (and with it the log package)
+// write text or JSON records to w.
+func setupLogging(w io.Writer, format string) error {
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var h slog.Handler
+	switch format {
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	case "json":
+		h = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format %q, expected text or json", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// fatal logs err at error level and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
+}
+
+// ConsoleLogger is a session's logger. Every record carries the session ID
+// and goes to the server log; records at consoleLevel or above are also sent
+// to the browser console of the connection the session is attached to.
+type ConsoleLogger struct {
+	*slog.Logger
+
+	mu   sync.Mutex
+	send func(typ string, payload any) error // control channel to the browser
+}
+
+func newConsoleLogger(sessionID string) *ConsoleLogger {
+	cl := &ConsoleLogger{}
+	h := teeHandler{slog.Default().Handler(), &consoleHandler{cl: cl}}
+	cl.Logger = slog.New(h).With("session", sessionID)
+	return cl
+}
+
+// setControl points the logger at the control channel of the connection a
+// session is attached to.
+func (cl *ConsoleLogger) setControl(send func(typ string, payload any) error) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	cl.send = send
+}
+
+func (cl *ConsoleLogger) LogInfo(message string) {
+	cl.Info(message)
+}
+
+func (cl *ConsoleLogger) LogError(message string) {
+	cl.Error(message)
+}
+
+func (cl *ConsoleLogger) LogDebug(message string) {
+	cl.Debug(message)
+}
+
+// consoleHandler is the slog.Handler that forwards records to the browser.
+// The session attribute is left out; the browser knows which session it is.
+type consoleHandler struct {
+	cl    *ConsoleLogger
+	attrs []slog.Attr
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= consoleLevel.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	h.cl.mu.Lock()
+	send := h.cl.send
+	h.cl.mu.Unlock()
+	if send == nil {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString(r.Message)
+	write := func(a slog.Attr) bool {
+		if a.Key != "session" {
+			fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		write(a)
+	}
+	r.Attrs(write)
+
+	return send(msgConsole, consoleLogMsg{
+		Level:   strings.ToLower(r.Level.String()),
+		Message: b.String(),
+	})
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &consoleHandler{cl: h.cl, attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)}
+}
+
+func (h *consoleHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// teeHandler passes every record to each of its handlers that is enabled for it.
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range t {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, r slog.Record) error {
+	for _, h := range t {
+		if h.Enabled(ctx, r.Level) {
+			_ = h.Handle(ctx, r.Clone())
+		}
+	}
+	return nil
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	out := make(teeHandler, len(t))
+	for i, h := range t {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
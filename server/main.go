@@ -1,207 +1,175 @@
+//go:build !js
+
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"log"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	tl "github.com/JoelOtter/termloop"
-	tea "github.com/charmbracelet/bubbletea"
-	"github.com/gorilla/websocket"
+	"github.com/charmbracelet/x/term"
 )
 
-// Buffer with blocking reads similar to wasm MinReadBuffer
-// Provides minimal backpressure and simple API for tea.WithInput
-// and for writing from WebSocket
-// Not safe for concurrent writes; guarded by single goroutine usage here
-
-type MinReadBuffer struct{ buf *bytes.Buffer }
-
-func (b *MinReadBuffer) Read(p []byte) (int, error) {
-	for b.buf.Len() == 0 {
-		time.Sleep(30 * time.Millisecond)
-	}
-	return b.buf.Read(p)
-}
-func (b *MinReadBuffer) Write(p []byte) (int, error) { return b.buf.Write(p) }
-
-// Define the same interface as src/lib/wasm/main_wasm.go expects
-
-type ioWriter interface{ Write([]byte) (int, error) }
-
-type ioReader interface{ Read([]byte) (int, error) }
-
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
-}
-
-type resizeMsg struct {
-	Type string `json:"type"`
-	Cols int    `json:"cols"`
-	Rows int    `json:"rows"`
-}
-
-type consoleLogMsg struct {
-	Type    string `json:"type"`
-	Level   string `json:"level"`
-	Message string `json:"message"`
-}
-
-// ConsoleLogger wraps a WebSocket connection to send console log messages
-type ConsoleLogger struct {
-	conn *websocket.Conn
-}
-
-func (cl *ConsoleLogger) Log(level, message string) {
-	if cl.conn == nil {
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "stats" {
+		if err := runStats(os.Args[2:], os.Stdout); err != nil && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
 		return
 	}
 
-	msg := consoleLogMsg{
-		Type:    "console",
-		Level:   level,
-		Message: message,
+	cfg, printConfig, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
 	}
-
-	data, err := json.Marshal(msg)
 	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if printConfig {
+		_ = cfg.print(os.Stdout)
 		return
 	}
+	cfg.apply()
 
-	cl.conn.WriteMessage(websocket.TextMessage, data)
-}
-
-func (cl *ConsoleLogger) LogInfo(message string) {
-	cl.Log("info", message)
-}
-
-func (cl *ConsoleLogger) LogError(message string) {
-	cl.Log("error", message)
-}
+	// In local mode the session owns the terminal; only log if stderr goes elsewhere
+	var logOut io.Writer = os.Stderr
+	if localMode && term.IsTerminal(os.Stderr.Fd()) {
+		logOut = io.Discard
+	}
+	if err := setupLogging(logOut, cfg.LogFormat); err != nil {
+		fatal("logging", err)
+	}
 
-func (cl *ConsoleLogger) LogDebug(message string) {
-	cl.Log("debug", message)
-}
+	if journalPath != "" {
+		if journal, err = openJournal(journalPath); err != nil {
+			fatal("journal", err)
+		}
+		defer journal.Close()
+	}
 
-func handleWS(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Println("upgrade:", err)
+	if localMode {
+		if err := runLocal(); err != nil {
+			fatal("local mode", err)
+		}
 		return
 	}
-	defer conn.Close()
-
-	// Create console logger
-	consoleLogger := &ConsoleLogger{conn: conn}
-	consoleLogger.LogInfo("WebSocket connection established")
 
-	fromClient := &MinReadBuffer{buf: bytes.NewBuffer(nil)}
-	toClient := bytes.NewBuffer(nil)
-
-	// Wire termloop shim IO so the snake game renders to WebSocket
-	tl.SetIO(fromClient, toClient)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", handleWS)
+	mux.HandleFunc("/ws/watch/{id}", handleWatch)
+	mux.HandleFunc("GET /sse", handleSSE)
+	mux.HandleFunc("POST /sse/input", handleSSEInput)
+	mux.HandleFunc("GET /recordings/{id}", handleRecording)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	mux.HandleFunc("GET /healthz", handleHealthz)
+	mux.HandleFunc("GET /readyz", handleReadyz)
+	mux.HandleFunc("GET /version", handleVersion)
+	mux.HandleFunc("POST /admin/broadcast", adminOnly(handleBroadcast))
+	mux.HandleFunc("GET /admin/stats", adminOnly(handleStats))
+	mux.Handle("/", newWebHandler(webFS()))
+
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		fatal("tls", err)
+	}
+	srv := &http.Server{Addr: cfg.Addr, Handler: mux, TLSConfig: tlsConfig}
 
-	// Output pump → send as text frames
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	go func() {
-		ticker := time.NewTicker(40 * time.Millisecond)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				if toClient.Len() == 0 {
-					continue
-				}
-				b := make([]byte, toClient.Len())
-				_, _ = toClient.Read(b)
-				_ = conn.WriteMessage(websocket.TextMessage, b)
-			}
+		slog.Info("server listening", "addr", cfg.Addr, "tls", tlsConfig != nil, "version", version)
+		var err error
+		if tlsConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
 		}
-	}()
-
-	// Reader pump → keystrokes and resize
-	resizeQueue := make(chan tea.WindowSizeMsg, 4)
-	go func() {
-		for {
-			msgType, data, err := conn.ReadMessage()
-			if err != nil {
-				cancel()
-				return
-			}
-			if msgType == websocket.TextMessage {
-				// try parse resize
-				var rm resizeMsg
-				if err := json.Unmarshal(data, &rm); err == nil && rm.Type == "resize" {
-					select {
-					case resizeQueue <- tea.WindowSizeMsg{Width: rm.Cols, Height: rm.Rows}:
-					default:
-					}
-					continue
-				}
-			}
-			// translate CR to LF so both CLI and Bubble Tea see newlines
-			for i := range data {
-				if data[i] == '\r' {
-					data[i] = '\n'
-				}
-			}
-			fromClient.Write(data)
+		if err != nil && err != http.ErrServerClosed {
+			fatal("http", err)
 		}
 	}()
 
-	// Run CLI first
-	cli(fromClient, toClient, consoleLogger)
-
-	// Start Bubble Tea
-	p := tea.NewProgram(initialModel(), tea.WithInput(fromClient), tea.WithOutput(toClient), tea.WithAltScreen())
+	go pruneRecordingsEvery(time.Hour)
 
-	// Drain any queued resizes once program is ready, and forward subsequent ones
-	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case rs := <-resizeQueue:
-				p.Send(rs)
+	var redirect *http.Server
+	if cfg.RedirectAddr != "" {
+		redirect = &http.Server{Addr: cfg.RedirectAddr, Handler: httpsRedirect(cfg.Addr), ReadHeaderTimeout: handshakeTimeout}
+		go func() {
+			slog.Info("redirecting to https", "addr", cfg.RedirectAddr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				fatal("redirect", err)
 			}
-		}
-	}()
-
-	if _, err := p.Run(); err != nil {
-		toClient.Write([]byte("error: "))
-		toClient.Write([]byte(err.Error()))
+		}()
 	}
 
-	cancel()
-}
-
-func main() {
-	mux := http.NewServeMux()
-	mux.HandleFunc("/ws", handleWS)
-
-	srv := &http.Server{Addr: ":8080", Handler: mux}
+	var listeners []net.Listener
+	if sshAddr != "" {
+		config, err := newSSHConfig(sshHostKeyPath)
+		if err != nil {
+			fatal("ssh", err)
+		}
+		ln, err := net.Listen("tcp", sshAddr)
+		if err != nil {
+			fatal("ssh", err)
+		}
+		listeners = append(listeners, ln)
+		slog.Info("ssh listening", "addr", ln.Addr().String())
+		go serveSSH(ln, config)
+	}
 
-	go func() {
-		log.Println("server listening on http://localhost:8080")
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal(err)
+	if telnetAddr != "" {
+		ln, err := net.Listen("tcp", telnetAddr)
+		if err != nil {
+			fatal("telnet", err)
 		}
-	}()
+		listeners = append(listeners, ln)
+		slog.Info("telnet listening", "addr", ln.Addr().String())
+		go serveTelnet(ln)
+	}
 
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
 	<-sig
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// Stop taking connections, then tell the visitors and give their
+	// transports time to send the close message
+	slog.Info("shutting down", "sessions", metrics.sessionsActive.n.Load())
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
 	defer cancel()
-	_ = srv.Shutdown(ctx)
+	if redirect != nil {
+		_ = redirect.Shutdown(ctx)
+	}
+	// Event streams only end with their sessions, so the HTTP server
+	// finishes shutting down alongside them
+	httpDone := make(chan struct{})
+	go func() {
+		_ = srv.Shutdown(ctx)
+		close(httpDone)
+	}()
+	for _, ln := range listeners {
+		ln.Close()
+	}
+	if err := sessions.shutdown(ctx); err != nil {
+		slog.Warn("sessions did not end in time", "err", err)
+	}
+	drained := make(chan struct{})
+	go func() {
+		transports.Wait()
+		<-httpDone
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		slog.Warn("connections did not close in time")
+	}
 }
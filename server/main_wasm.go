@@ -0,0 +1,101 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"os"
+	"syscall/js"
+)
+
+// embeddedContent is the portfolio content, built in so the browser needs
+// nothing but the .wasm file.
+//
+//go:embed content/*.json
+var embeddedContent embed.FS
+
+// main runs one session in the browser, for static hosting without the
+// server. The page drives it through the global tuiPortfolio object:
+//
+//	tuiPortfolio.resize(cols, rows) // the terminal size, before start and on every change
+//	tuiPortfolio.start(write, caps) // write(bytes: Uint8Array) receives the output
+//	tuiPortfolio.input(data)        // keystrokes, as a string
+//
+// caps is optional and has the shape of the caps in the /ws hello. Invalid
+// arguments make start return an Error instead of starting the session.
+// tuiPortfolio exists as soon as go.run(instance) returns; the promise it
+// returns resolves once the session is over.
+func main() {
+	if err := setupLogging(os.Stderr, "text"); err != nil {
+		fatal("logging", err)
+	}
+	contentFS, _ = fs.Sub(embeddedContent, "content")
+	// The browser build has no configuration. Like --local it runs the only
+	// session, so it takes loadConfig's --local defaults: no timeouts
+	idleTimeout, maxSessionDuration = 0, 0
+
+	var write js.Value // the page's output callback, set by start
+	send := func(b []byte) error {
+		buf := js.Global().Get("Uint8Array").New(len(b))
+		js.CopyBytesToJS(buf, b)
+		write.Invoke(buf)
+		return nil
+	}
+	sess := newSession(context.Background(), send)
+
+	// A panic would end the Go runtime for the whole page, so bad arguments
+	// from JavaScript are reported as Errors or ignored
+	jsError := func(msg string) js.Value {
+		return js.Global().Get("Error").New("tuiPortfolio." + msg)
+	}
+
+	done := make(chan struct{})
+	started := false
+	api := js.Global().Get("Object").New()
+	api.Set("resize", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 2 && args[0].Type() == js.TypeNumber && args[1].Type() == js.TypeNumber {
+			sess.size.set(args[0].Int(), args[1].Int())
+		}
+		return nil
+	}))
+	api.Set("input", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if len(args) == 1 && args[0].Type() == js.TypeString {
+			sess.input([]byte(args[0].String()))
+		}
+		return nil
+	}))
+	api.Set("start", js.FuncOf(func(this js.Value, args []js.Value) any {
+		if started {
+			return jsError("start: the session has already started")
+		}
+		if len(args) < 1 || args[0].Type() != js.TypeFunction {
+			return jsError("start needs a write callback")
+		}
+		caps := xtermCapabilities
+		if len(args) > 1 && !args[1].IsUndefined() && !args[1].IsNull() {
+			if args[1].Type() != js.TypeObject {
+				return jsError("start: caps must be an object")
+			}
+			data := js.Global().Get("JSON").Call("stringify", args[1]).String()
+			if err := json.Unmarshal([]byte(data), &caps); err != nil {
+				return jsError("start: invalid caps: " + err.Error())
+			}
+		}
+		started = true
+		write = args[0]
+		sess.setCaps(caps)
+		go func() {
+			defer close(done)
+			sess.run()
+			if reason := sess.reason(); reason != "" {
+				send([]byte("\r\n[" + reason + "]\r\n"))
+			}
+		}()
+		return nil
+	}))
+	js.Global().Set("tuiPortfolio", api)
+	<-done
+}
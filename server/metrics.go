@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// counter is a metric that only goes up.
+type counter struct{ n atomic.Uint64 }
+
+func (c *counter) inc()         { c.n.Add(1) }
+func (c *counter) add(n uint64) { c.n.Add(n) }
+
+// gauge is a metric that goes up and down.
+type gauge struct{ n atomic.Int64 }
+
+func (g *gauge) inc() { g.n.Add(1) }
+func (g *gauge) dec() { g.n.Add(-1) }
+
+// counterVec is a counter partitioned by the value of one label.
+type counterVec struct {
+	label  string
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounterVec(label string) *counterVec {
+	return &counterVec{label: label, values: make(map[string]uint64)}
+}
+
+func (v *counterVec) inc(value string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.values[value]++
+}
+
+// histogram counts observations into cumulative buckets with the given
+// upper bounds, like a Prometheus histogram.
+type histogram struct {
+	bounds []float64
+
+	mu     sync.Mutex
+	counts []uint64 // per bucket, not cumulative; the last is +Inf
+	sum    float64
+	total  uint64
+}
+
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.total++
+}
+
+// metrics collects what /metrics reports.
+var metrics = struct {
+	sessionsActive  gauge
+	sessionsTotal   counter
+	sessionDuration *histogram
+	bytesIn         counter
+	inputDropped    counter
+	bytesOut        counter
+	outputDropped   counter
+	commands        *counterVec
+	unknownCommands counter
+	snakeGames      counter
+	snakeScores     *histogram
+	teaRuns         counter
+}{
+	sessionDuration: newHistogram(10, 30, 60, 300, 600, 1800, 3600, 7200),
+	commands:        newCounterVec("command"),
+	snakeScores:     newHistogram(0, 5, 10, 25, 50, 100, 250, 500),
+}
+
+// handleMetrics serves the metrics in the Prometheus text exposition format.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m := &metrics
+	writeMetric(w, "portfolio_sessions_active", "gauge", "Sessions currently running.", float64(m.sessionsActive.n.Load()))
+	writeMetric(w, "portfolio_sessions_total", "counter", "Sessions started.", float64(m.sessionsTotal.n.Load()))
+	writeHistogram(w, "portfolio_session_duration_seconds", "How long sessions lasted.", m.sessionDuration)
+	writeMetric(w, "portfolio_input_bytes_total", "counter", "Keystroke bytes received from visitors.", float64(m.bytesIn.n.Load()))
+	writeMetric(w, "portfolio_input_dropped_bytes_total", "counter", "Keystroke bytes dropped because no program was reading input.", float64(m.inputDropped.n.Load()))
+	writeMetric(w, "portfolio_output_bytes_total", "counter", "Terminal output bytes sent to visitors.", float64(m.bytesOut.n.Load()))
+	writeMetric(w, "portfolio_output_dropped_bytes_total", "counter", "Terminal output bytes dropped while a transport stalled.", float64(m.outputDropped.n.Load()))
+	writeCounterVec(w, "portfolio_commands_total", "Shell commands executed, by name.", m.commands)
+	writeMetric(w, "portfolio_unknown_commands_total", "counter", "Shell commands that were not recognized.", float64(m.unknownCommands.n.Load()))
+	writeMetric(w, "portfolio_snake_games_total", "counter", "Snake games started.", float64(m.snakeGames.n.Load()))
+	writeHistogram(w, "portfolio_snake_score", "Final scores of finished snake rounds.", m.snakeScores)
+	writeMetric(w, "portfolio_tea_runs_total", "counter", "Bubble Tea program runs.", float64(m.teaRuns.n.Load()))
+}
+
+func writeHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeMetric(w io.Writer, name, typ, help string, v float64) {
+	writeHeader(w, name, typ, help)
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+}
+
+func writeCounterVec(w io.Writer, name, help string, v *counterVec) {
+	writeHeader(w, name, "counter", help)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%s} %d\n", name, v.label, quoteLabel(k), v.values[k])
+	}
+}
+
+func writeHistogram(w io.Writer, name, help string, h *histogram) {
+	writeHeader(w, name, "histogram", help)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(bound), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.total)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.total)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// quoteLabel quotes a label value, escaping backslashes, quotes and newlines.
+func quoteLabel(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
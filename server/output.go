@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// echoMaxBytes is the largest write that counts as interactive echo.
+	// Such writes are sent right away when the pump has been quiet for a
+	// batch interval, so typing feels immediate.
+	echoMaxBytes = 64
+	// maxFrameBytes caps the size of a single output frame.
+	maxFrameBytes = 32 << 10
+	// maxPendingBytes caps the output waiting to be sent. Beyond it, output
+	// is dropped until a stalled transport catches up or is detached.
+	maxPendingBytes = 1 << 20
+)
+
+// outputBatchInterval is how long bulk output is coalesced before it is sent,
+// and how long an incomplete trailing sequence is held back.
+var outputBatchInterval = 40 * time.Millisecond
+
+// outputPump collects terminal output from any number of goroutines and hands
+// it to send in frames that never split a UTF-8 rune or an escape sequence.
+// Small writes after a quiet period are flushed immediately; everything else
+// is batched for outputBatchInterval.
+type outputPump struct {
+	send func([]byte) error
+	// tap, if set before run, sees every chunk exactly as it is sent
+	tap func([]byte)
+
+	mu        sync.Mutex
+	buf       []byte
+	heldSince time.Time // when an incomplete tail was first held back
+	lastFlush time.Time
+	notify    chan struct{}
+}
+
+func newOutputPump(send func([]byte) error) *outputPump {
+	return &outputPump{send: send, notify: make(chan struct{}, 1)}
+}
+
+func (p *outputPump) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	if len(p.buf)+len(b) > maxPendingBytes {
+		p.mu.Unlock()
+		metrics.outputDropped.add(uint64(len(b)))
+		return len(b), nil
+	}
+	p.buf = append(p.buf, b...)
+	p.mu.Unlock()
+	p.wake()
+	return len(b), nil
+}
+
+func (p *outputPump) wake() {
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (p *outputPump) pending() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.buf)
+}
+
+// run forwards output until ctx ends, then flushes whatever is left.
+func (p *outputPump) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			p.flush(true)
+			return
+		case <-p.notify:
+		}
+
+		p.mu.Lock()
+		interactive := len(p.buf) <= echoMaxBytes && time.Since(p.lastFlush) >= outputBatchInterval
+		p.mu.Unlock()
+
+		if !interactive {
+			batch := time.NewTimer(outputBatchInterval)
+		collect:
+			for p.pending() < maxFrameBytes {
+				select {
+				case <-ctx.Done():
+					batch.Stop()
+					p.flush(true)
+					return
+				case <-batch.C:
+					break collect
+				case <-p.notify:
+				}
+			}
+			batch.Stop()
+		}
+		p.flush(false)
+	}
+}
+
+// flush sends everything up to the last safe boundary. An incomplete tail is
+// kept for the next flush, unless it has been held for a full batch interval
+// or force is set, in which case it goes out as is.
+func (p *outputPump) flush(force bool) {
+	p.mu.Lock()
+	cut := safeCut(p.buf)
+	if cut < len(p.buf) {
+		if force || (!p.heldSince.IsZero() && time.Since(p.heldSince) >= outputBatchInterval) {
+			cut = len(p.buf)
+		} else if p.heldSince.IsZero() {
+			p.heldSince = time.Now()
+			time.AfterFunc(outputBatchInterval, p.wake)
+		}
+	}
+	if cut == len(p.buf) {
+		p.heldSince = time.Time{}
+	}
+	if cut == 0 {
+		p.mu.Unlock()
+		return
+	}
+	out := make([]byte, cut)
+	copy(out, p.buf[:cut])
+	p.buf = append(p.buf[:0], p.buf[cut:]...)
+	p.lastFlush = time.Now()
+	p.mu.Unlock()
+
+	if p.tap != nil {
+		p.tap(out)
+	}
+
+	for len(out) > 0 {
+		n := len(out)
+		if n > maxFrameBytes {
+			if n = safeCut(out[:maxFrameBytes]); n == 0 {
+				n = maxFrameBytes
+			}
+		}
+		if err := p.send(out[:n]); err != nil {
+			return
+		}
+		out = out[n:]
+	}
+}
+
+// lfToCRLF wraps send so bare LFs go out as CRLF. xterm.js does this itself
+// (convertEol), but terminals on the other end of SSH or Telnet are in raw
+// mode and need the carriage return.
+func lfToCRLF(send func([]byte) error) func([]byte) error {
+	prevCR := false
+	return func(b []byte) error {
+		out := make([]byte, 0, len(b)+len(b)/8)
+		for _, c := range b {
+			if c == '\n' && !prevCR {
+				out = append(out, '\r')
+			}
+			out = append(out, c)
+			prevCR = c == '\r'
+		}
+		return send(out)
+	}
+}
+
+// safeCut returns the length of the longest prefix of b that does not end in
+// the middle of a UTF-8 rune or an ANSI escape sequence.
+func safeCut(b []byte) int {
+	safe := 0
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == 0x1b:
+			n := escapeLen(b[i:])
+			if n == 0 {
+				return safe
+			}
+			i += n
+		case c < utf8.RuneSelf:
+			i++
+		default:
+			if !utf8.FullRune(b[i:]) {
+				return safe
+			}
+			_, size := utf8.DecodeRune(b[i:])
+			i += size
+		}
+		safe = i
+	}
+	return safe
+}
+
+// rangBell reports whether b rings the terminal bell: a BEL outside escape
+// sequences, where it would end an OSC instead.
+func rangBell(b []byte) bool {
+	for i := 0; i < len(b); {
+		switch b[i] {
+		case 0x07:
+			return true
+		case 0x1b:
+			n := escapeLen(b[i:])
+			if n == 0 {
+				return false
+			}
+			i += n
+		default:
+			i++
+		}
+	}
+	return false
+}
+
+// escapeLen returns the length of the escape sequence at the start of b, or 0
+// if b ends before the sequence does.
+func escapeLen(b []byte) int {
+	if len(b) < 2 {
+		return 0
+	}
+	switch b[1] {
+	case '[': // CSI: parameters and intermediates, then a final byte
+		for j := 2; j < len(b); j++ {
+			if b[j] >= 0x40 && b[j] <= 0x7e {
+				return j + 1
+			}
+			if b[j] < 0x20 || b[j] > 0x3f {
+				return j // malformed, end it here
+			}
+		}
+		return 0
+	case ']', 'P', '_', '^', 'X': // OSC, DCS, APC, PM, SOS: end with BEL or ST
+		for j := 2; j < len(b); j++ {
+			if b[j] == 0x07 {
+				return j + 1
+			}
+			if b[j] == 0x1b && j+1 < len(b) && b[j+1] == '\\' {
+				return j + 2
+			}
+		}
+		return 0
+	}
+	// nF sequences (e.g. ESC ( B): intermediates followed by a final byte
+	j := 1
+	for j < len(b) && b[j] >= 0x20 && b[j] <= 0x2f {
+		j++
+	}
+	if j == len(b) {
+		return 0
+	}
+	return j + 1
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSafeCut(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"plain", "hello", 5},
+		{"empty", "", 0},
+		{"complete CSI", "a\033[1;31mb", 9},
+		{"split CSI", "a\033[1;3", 1},
+		{"lone ESC", "ab\033", 2},
+		{"malformed CSI", "\033[1\x01x", 5},
+		{"OSC ended by BEL", "\033]0;title\007x", 11},
+		{"OSC ended by ST", "\033]0;title\033\\x", 12},
+		{"split OSC", "a\033]0;tit", 1},
+		{"OSC split in ST", "a\033]0;title\033", 1},
+		{"nF sequence", "\033(Bx", 4},
+		{"split nF sequence", "x\033(", 1},
+		{"two-byte escape", "\033cx", 3},
+		{"complete rune", "é", 2},
+		{"split two-byte rune", "a\xc3", 1},
+		{"split three-byte rune", "a→"[:3], 1},
+		{"split four-byte rune", "a🐍"[:4], 1},
+		{"invalid byte", "a\xffb", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := safeCut([]byte(tt.in)); got != tt.want {
+				t.Errorf("safeCut(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeLen(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"\033", 0},
+		{"\033[", 0},
+		{"\033[H", 3},
+		{"\033[?25l", 6},
+		{"\033]8;;http://x\007", 14},
+		{"\033]8;;http://x\033\\", 15},
+		{"\033Pq#0\033\\", 7},
+		{"\033_Gf=24\033\\", 9},
+		{"\033(", 0},
+		{"\033(B", 3},
+		{"\033 F", 3},
+		{"\0337", 2},
+	}
+	for _, tt := range tests {
+		if got := escapeLen([]byte(tt.in)); got != tt.want {
+			t.Errorf("escapeLen(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+// recordFrames returns a pump whose frames are appended to *frames.
+func recordFrames(frames *[]string) *outputPump {
+	return newOutputPump(func(b []byte) error {
+		*frames = append(*frames, string(b))
+		return nil
+	})
+}
+
+func TestOutputPumpFrameSplit(t *testing.T) {
+	var frames []string
+	p := recordFrames(&frames)
+	// Put a CSI sequence across the frame boundary
+	out := strings.Repeat("x", maxFrameBytes-2) + "\033[31m" + strings.Repeat("y", 10)
+	p.Write([]byte(out))
+	p.flush(false)
+
+	if len(frames) != 2 {
+		t.Fatalf("got %d frames, want 2", len(frames))
+	}
+	if len(frames[0]) != maxFrameBytes-2 {
+		t.Errorf("first frame is %d bytes, want %d", len(frames[0]), maxFrameBytes-2)
+	}
+	if !strings.HasPrefix(frames[1], "\033[31m") {
+		t.Errorf("second frame starts with %q, want the whole CSI sequence", frames[1][:5])
+	}
+	if got := strings.Join(frames, ""); got != out {
+		t.Error("frames don't add up to the output")
+	}
+}
+
+func TestOutputPumpHeldTail(t *testing.T) {
+	tests := []struct {
+		name  string
+		flush func(p *outputPump)
+	}{
+		{"forced", func(p *outputPump) { p.flush(true) }},
+		{"held a batch interval", func(p *outputPump) {
+			p.heldSince = time.Now().Add(-outputBatchInterval)
+			p.flush(false)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var frames []string
+			p := recordFrames(&frames)
+			p.Write([]byte("abc\033[3"))
+			p.flush(false)
+			if len(frames) != 1 || frames[0] != "abc" {
+				t.Fatalf("first flush sent %q, want only the complete prefix", frames)
+			}
+			if p.heldSince.IsZero() {
+				t.Fatal("incomplete tail wasn't marked as held")
+			}
+
+			tt.flush(p)
+			if len(frames) != 2 || frames[1] != "\033[3" {
+				t.Fatalf("second flush sent %q, want the held tail", frames[1:])
+			}
+			if !p.heldSince.IsZero() || p.pending() != 0 {
+				t.Error("pump still holds output after the tail went out")
+			}
+		})
+	}
+}
+
+func TestOutputPumpCompletedTail(t *testing.T) {
+	var frames []string
+	p := recordFrames(&frames)
+	p.Write([]byte("\xe2\x86"))
+	p.flush(false)
+	if len(frames) != 0 {
+		t.Fatalf("sent %q for an incomplete rune", frames)
+	}
+	p.Write([]byte("\x92"))
+	p.flush(false)
+	if strings.Join(frames, "") != "→" || !p.heldSince.IsZero() {
+		t.Errorf("sent %q, want the completed rune", frames)
+	}
+}
+
+func TestRangBell(t *testing.T) {
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"", false},
+		{"\a", true},
+		{"abc\a", true},
+		{"\033]0;title\a", false},
+		{"\033]0;title\a\a", true},
+		{"\033[31m\a", true},
+		{"\033]0;tit", false},
+	}
+	for _, tt := range tests {
+		if got := rangBell([]byte(tt.in)); got != tt.want {
+			t.Errorf("rangBell(%q) = %t, want %t", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxPendingInput caps the keystrokes waiting for a program to read them.
+// While nothing reads, e.g. during a typewriter rendering, further input is
+// dropped rather than buffered without limit.
+const maxPendingInput = 64 << 10
+
+var errInputFull = errors.New("input buffer full")
+
+// inputPipe carries keystrokes from a transport's reader goroutine to whichever
+// program currently consumes input (cli, the snake game or Bubble Tea).
+// It is safe for concurrent use. Reads block until bytes arrive and return
+// io.EOF once the pipe is closed, or the context error once the session ends.
+type inputPipe struct {
+	ctx context.Context
+
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	wake     chan struct{} // closed and replaced whenever readers should re-check
+	closed   bool
+	deadline time.Time
+}
+
+func newInputPipe(ctx context.Context) *inputPipe {
+	return &inputPipe{ctx: ctx, wake: make(chan struct{})}
+}
+
+// broadcast wakes all blocked readers. Callers must hold p.mu.
+func (p *inputPipe) broadcast() {
+	close(p.wake)
+	p.wake = make(chan struct{})
+}
+
+func (p *inputPipe) Read(b []byte) (int, error) {
+	for {
+		p.mu.Lock()
+		if p.buf.Len() > 0 {
+			n, err := p.buf.Read(b)
+			p.mu.Unlock()
+			return n, err
+		}
+		if p.closed {
+			p.mu.Unlock()
+			return 0, io.EOF
+		}
+		if err := p.ctx.Err(); err != nil {
+			p.mu.Unlock()
+			return 0, err
+		}
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if !p.deadline.IsZero() {
+			d := time.Until(p.deadline)
+			if d <= 0 {
+				p.mu.Unlock()
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		wake := p.wake
+		p.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-timeout:
+		case <-p.ctx.Done():
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (p *inputPipe) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if p.buf.Len()+len(b) > maxPendingInput {
+		metrics.inputDropped.add(uint64(len(b)))
+		return 0, errInputFull
+	}
+	n, err := p.buf.Write(b)
+	p.broadcast()
+	return n, err
+}
+
+// Close makes pending and future reads return io.EOF once buffered bytes are drained.
+func (p *inputPipe) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		p.broadcast()
+	}
+	return nil
+}
+
+// SetReadDeadline works like net.Conn's: a blocked Read returns
+// os.ErrDeadlineExceeded once t has passed. A zero t clears the deadline.
+// The termloop shim uses it to release its reader goroutine when a game ends.
+func (p *inputPipe) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadline = t
+	p.broadcast()
+	return nil
+}
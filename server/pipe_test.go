@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInputPipeRead(t *testing.T) {
+	p := newInputPipe(context.Background())
+	go p.Write([]byte("ls\n"))
+	buf := make([]byte, 16)
+	n, err := p.Read(buf)
+	if err != nil || string(buf[:n]) != "ls\n" {
+		t.Fatalf("Read = %q, %v; want %q", buf[:n], err, "ls\n")
+	}
+}
+
+func TestInputPipeDeadline(t *testing.T) {
+	p := newInputPipe(context.Background())
+	buf := make([]byte, 16)
+
+	p.SetReadDeadline(time.Now().Add(20 * time.Millisecond))
+	start := time.Now()
+	if _, err := p.Read(buf); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("Read past the deadline returned %v, want os.ErrDeadlineExceeded", err)
+	}
+	if waited := time.Since(start); waited < 15*time.Millisecond {
+		t.Errorf("Read returned after %v, before the deadline", waited)
+	}
+
+	// A deadline set while a Read is blocked releases it
+	done := make(chan error, 1)
+	p.SetReadDeadline(time.Time{})
+	go func() {
+		_, err := p.Read(buf)
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	p.SetReadDeadline(time.Now())
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("blocked Read returned %v, want os.ErrDeadlineExceeded", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SetReadDeadline didn't release a blocked Read")
+	}
+
+	// Clearing the deadline makes the pipe readable again
+	p.SetReadDeadline(time.Time{})
+	p.Write([]byte("x"))
+	if n, err := p.Read(buf); err != nil || string(buf[:n]) != "x" {
+		t.Errorf("Read after clearing the deadline = %q, %v", buf[:n], err)
+	}
+}
+
+func TestInputPipeClose(t *testing.T) {
+	p := newInputPipe(context.Background())
+	p.Write([]byte("left"))
+	p.Close()
+	if _, err := p.Write([]byte("more")); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Write after Close returned %v, want io.ErrClosedPipe", err)
+	}
+	data, err := io.ReadAll(p)
+	if err != nil || string(data) != "left" {
+		t.Errorf("ReadAll after Close = %q, %v; want the buffered input", data, err)
+	}
+}
+
+func TestInputPipeContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := newInputPipe(ctx)
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.Read(make([]byte, 1))
+		done <- err
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Read returned %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("ending the session didn't release a blocked Read")
+	}
+}
+
+func TestInputPipeLimit(t *testing.T) {
+	p := newInputPipe(context.Background())
+	chunk := []byte(strings.Repeat("a", maxPendingInput/2))
+	for i := 0; i < 2; i++ {
+		if _, err := p.Write(chunk); err != nil {
+			t.Fatalf("Write %d within the limit: %v", i, err)
+		}
+	}
+	if _, err := p.Write([]byte("b")); !errors.Is(err, errInputFull) {
+		t.Fatalf("Write beyond the limit returned %v, want errInputFull", err)
+	}
+
+	// Reading makes room again
+	p.Read(make([]byte, len(chunk)))
+	if _, err := p.Write([]byte("b")); err != nil {
+		t.Errorf("Write after a Read: %v", err)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -21,11 +21,16 @@ type PortfolioManager struct {
 }
 
 func NewPortfolioManager(contentDir string) (*PortfolioManager, error) {
+	return NewPortfolioManagerFS(os.DirFS(contentDir))
+}
+
+// NewPortfolioManagerFS loads the sections from the JSON files at the root of fsys.
+func NewPortfolioManagerFS(fsys fs.FS) (*PortfolioManager, error) {
 	pm := &PortfolioManager{
 		sections: make(map[string]PortfolioSection),
 	}
 
-	err := pm.loadSections(contentDir)
+	err := pm.loadSections(fsys)
 	if err != nil {
 		return nil, err
 	}
@@ -33,14 +38,14 @@ func NewPortfolioManager(contentDir string) (*PortfolioManager, error) {
 	return pm, nil
 }
 
-func (pm *PortfolioManager) loadSections(contentDir string) error {
-	files, err := filepath.Glob(filepath.Join(contentDir, "*.json"))
+func (pm *PortfolioManager) loadSections(fsys fs.FS) error {
+	files, err := fs.Glob(fsys, "*.json")
 	if err != nil {
 		return err
 	}
 
 	for _, file := range files {
-		data, err := os.ReadFile(file)
+		data, err := fs.ReadFile(fsys, file)
 		if err != nil {
 			continue // skip files we can't read
 		}
@@ -69,20 +74,29 @@ func (pm *PortfolioManager) GetAllCommands() []string {
 	return commands
 }
 
-func (pm *PortfolioManager) RenderSection(out io.Writer, section PortfolioSection) {
+// RenderSection writes a section to out. Rules are 60 columns wide, or the
+// terminal width if it is narrower; width 0 means unknown. The header is
+// highlighted in the colors caps allows, and the text falls back to ASCII
+// where the terminal lacks Unicode.
+func (pm *PortfolioManager) RenderSection(out io.Writer, section PortfolioSection, width int, caps capabilities) {
+	rule := 60
+	if width > 0 && width < rule {
+		rule = width
+	}
+
 	// Clear screen and show header
 	fmt.Fprint(out, "\033[H\033[2J")
-	fmt.Fprintf(out, "%s\n", strings.Repeat("=", 60))
-	fmt.Fprintf(out, "%s\n", section.Header)
-	fmt.Fprintf(out, "%s\n", strings.Repeat("=", 60))
+	fmt.Fprintf(out, "%s\n", strings.Repeat("=", rule))
+	fmt.Fprintf(out, "%s\n", caps.highlight(caps.text(section.Header)))
+	fmt.Fprintf(out, "%s\n", strings.Repeat("=", rule))
 	fmt.Fprintln(out)
 
 	// Render content
 	for _, line := range section.Content {
-		fmt.Fprintln(out, line)
+		fmt.Fprintln(out, caps.text(line))
 	}
 
 	fmt.Fprintln(out)
-	fmt.Fprintf(out, "%s\n", strings.Repeat("-", 60))
+	fmt.Fprintf(out, "%s\n", strings.Repeat("-", rule))
 	fmt.Fprintln(out, "Press Enter to return to main menu...")
 }
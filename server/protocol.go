@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// protocolVersion is the version of the /ws control protocol spoken by this
+// server. Clients announce theirs in the hello message; a mismatch closes the
+// connection.
+//
+// Text frames carry exactly one JSON envelope each. Terminal output travels in
+// binary frames and is never wrapped, so nothing a visitor types or a program
+// prints can be mistaken for a control message.
+const protocolVersion = 1
+
+// Control message types.
+const (
+	msgHello    = "hello"    // both directions, first message of a connection
+	msgSession  = "session"  // server → client, after hello: session id and resume token
+	msgInput    = "input"    // client → server, keystrokes
+	msgResize   = "resize"   // client → server, terminal size
+	msgPing     = "ping"     // both directions
+	msgPong     = "pong"     // both directions, echoes the ping nonce
+	msgConsole  = "console"  // server → client, browser console log line
+	msgTitle    = "title"    // server → client, window title
+	msgBell     = "bell"     // server → client, the output rang the bell
+	msgDownload = "download" // server → client, file offered to the visitor
+	msgClose    = "close"    // both directions, reason for ending the session
+	msgViewers  = "viewers"  // server → client, number of spectators watching
+)
+
+// envelope wraps every control message. Payload is decoded according to Type.
+type envelope struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type helloMsg struct {
+	Version int    `json:"version"`
+	Client  string `json:"client,omitempty"`
+	Resume  string `json:"resume,omitempty"` // client → server, token of a session to reattach
+	// Caps is sent by the client: what its terminal can display. Clients
+	// that leave it out get xtermCapabilities.
+	Caps *capabilities `json:"caps,omitempty"`
+}
+
+// capabilities returns the terminal capabilities announced in hello.
+func (h helloMsg) capabilities() capabilities {
+	if h.Caps == nil {
+		return xtermCapabilities
+	}
+	return *h.Caps
+}
+
+type sessionMsg struct {
+	ID      string `json:"id"`
+	Token   string `json:"token"`
+	Resumed bool   `json:"resumed"`
+}
+
+type inputMsg struct {
+	Data string `json:"data"`
+}
+
+type resizeMsg struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+type pingMsg struct {
+	Nonce int64 `json:"nonce"`
+}
+
+type consoleLogMsg struct {
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+type titleMsg struct {
+	Title string `json:"title"`
+}
+
+type downloadMsg struct {
+	Name string `json:"name"`
+	MIME string `json:"mime"`
+	Data []byte `json:"data"` // base64 in JSON
+}
+
+type viewersMsg struct {
+	Count int `json:"count"`
+}
+
+type closeMsg struct {
+	Code   int    `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// Close codes carried in closeMsg.
+const (
+	closeNormal          = 1000
+	closeServiceRestart  = 1012 // the server is restarting; reconnect shortly
+	closeProtocolError   = 4000
+	closeVersionMismatch = 4001
+	closeRejected        = 4002
+	closeNotFound        = 4003
+)
+
+// encodeControl builds the text frame for a control message. A nil payload
+// produces an envelope without one (e.g. bell).
+func encodeControl(typ string, payload any) ([]byte, error) {
+	env := envelope{Type: typ}
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		env.Payload = raw
+	}
+	return json.Marshal(env)
+}
+
+// decodeControl parses a text frame into its envelope.
+func decodeControl(data []byte) (envelope, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return env, fmt.Errorf("invalid control frame: %w", err)
+	}
+	if env.Type == "" {
+		return env, fmt.Errorf("control frame without type")
+	}
+	return env, nil
+}
+
+// decodePayload unmarshals the envelope's payload into v.
+func (env envelope) decodePayload(v any) error {
+	if len(env.Payload) == 0 {
+		return fmt.Errorf("%s message without payload", env.Type)
+	}
+	if err := json.Unmarshal(env.Payload, v); err != nil {
+		return fmt.Errorf("invalid %s payload: %w", env.Type, err)
+	}
+	return nil
+}
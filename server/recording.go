@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordingsDir is where session recordings are written. Empty disables recording.
+var recordingsDir string
+
+var (
+	// maxRecordingBytes caps the size of one recording; output beyond it is
+	// not recorded (0 for no limit).
+	maxRecordingBytes int64 = 16 << 20
+	// recordingRetention is how long recordings are kept (0 keeps them forever).
+	recordingRetention = 7 * 24 * time.Hour
+)
+
+// truncatedNotice ends a recording that reached maxRecordingBytes.
+const truncatedNotice = "\r\n[recording truncated]\r\n"
+
+// recordingIDPattern matches the IDs handed out by newSessionID; anything else
+// is rejected before it gets near the filesystem.
+var recordingIDPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// castRecorder writes a session's output stream and resizes as asciicast v2.
+type castRecorder struct {
+	mu        sync.Mutex
+	f         *os.File
+	w         *bufio.Writer
+	start     time.Time
+	written   int64
+	truncated bool // maxRecordingBytes was reached
+}
+
+func recordingPath(id string) string {
+	return filepath.Join(recordingsDir, id+".cast")
+}
+
+// newCastRecorder creates the recording for session id. The header uses the
+// size known at this point, or 80x24; later sizes are recorded as resize events.
+func newCastRecorder(id string, size *sizeSource) (*castRecorder, error) {
+	if err := os.MkdirAll(recordingsDir, 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(recordingPath(id))
+	if err != nil {
+		return nil, err
+	}
+	sz, ok := size.get()
+	if !ok {
+		sz = termSize{Cols: 80, Rows: 24}
+	}
+	r := &castRecorder{f: f, w: bufio.NewWriter(f), start: time.Now()}
+	header, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     sz.Cols,
+		Height:    sz.Rows,
+		Timestamp: r.start.Unix(),
+		Title:     "portfolio session " + id,
+		Env:       map[string]string{"TERM": "xterm-256color"},
+	})
+	r.w.Write(header)
+	r.w.WriteByte('\n')
+	r.written = int64(len(header)) + 1
+	return r, nil
+}
+
+func (r *castRecorder) event(code, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.truncated {
+		return
+	}
+	line, _ := json.Marshal([]any{time.Since(r.start).Seconds(), code, data})
+	if maxRecordingBytes > 0 && r.written+int64(len(line))+1 > maxRecordingBytes {
+		r.truncated = true
+		line, _ = json.Marshal([]any{time.Since(r.start).Seconds(), "o", truncatedNotice})
+	}
+	r.w.Write(line)
+	r.w.WriteByte('\n')
+	r.written += int64(len(line)) + 1
+	// Keep the file readable by /recordings while the session is live
+	r.w.Flush()
+}
+
+// output records a chunk of terminal output. It is the output pump's tap.
+func (r *castRecorder) output(b []byte) { r.event("o", string(b)) }
+
+func (r *castRecorder) resize(sz termSize) {
+	r.event("r", fmt.Sprintf("%dx%d", sz.Cols, sz.Rows))
+}
+
+func (r *castRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Flush()
+	return r.f.Close()
+}
+
+// pruneRecordings deletes recordings last written more than
+// recordingRetention ago.
+func pruneRecordings() {
+	entries, err := os.ReadDir(recordingsDir)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("recordings: prune failed", "err", err)
+		}
+		return
+	}
+	cutoff := time.Now().Add(-recordingRetention)
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), ".cast")
+		if !ok || !recordingIDPattern.MatchString(id) {
+			continue
+		}
+		if fi, err := e.Info(); err == nil && fi.ModTime().Before(cutoff) {
+			if err := os.Remove(recordingPath(id)); err != nil {
+				slog.Error("recordings: prune failed", "err", err)
+			} else {
+				slog.Info("recordings: pruned", "id", id)
+			}
+		}
+	}
+}
+
+// pruneRecordingsEvery prunes recordings now and then every interval, for
+// as long as the server runs. It does nothing if recordings are kept forever.
+func pruneRecordingsEvery(interval time.Duration) {
+	if recordingsDir == "" || recordingRetention <= 0 {
+		return
+	}
+	for {
+		pruneRecordings()
+		time.Sleep(interval)
+	}
+}
+
+// handleRecording streams a recording back as an asciicast v2 file.
+func handleRecording(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if recordingsDir == "" || !recordingIDPattern.MatchString(id) {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(recordingPath(id))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-asciicast")
+	http.ServeContent(w, r, id+".cast", fi.ModTime(), f)
+}
+
+// maxReplayIdle caps pauses during replay so idle stretches don't stall it.
+const maxReplayIdle = 2 * time.Second
+
+// openRecording opens recording id for replay.
+func openRecording(id string) (*os.File, error) {
+	if recordingsDir == "" {
+		return nil, fmt.Errorf("recordings are disabled on this server")
+	}
+	if !recordingIDPattern.MatchString(id) {
+		return nil, fmt.Errorf("invalid recording id %q", id)
+	}
+	f, err := os.Open(recordingPath(id))
+	if err != nil {
+		return nil, fmt.Errorf("no recording %s", id)
+	}
+	return f, nil
+}
+
+// replay plays an asciicast v2 recording into out at the given speed. It
+// stops early when ctx ends or any key is pressed on in.
+func replay(ctx context.Context, in *inputPipe, out io.Writer, rec io.Reader, speed float64) error {
+	// Any keystroke aborts the replay. The read is released through the
+	// pipe's deadline once playback ends so the CLI gets its input back.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		var b [1]byte
+		if n, _ := in.Read(b[:]); n > 0 {
+			cancel()
+		}
+	}()
+	defer func() {
+		_ = in.SetReadDeadline(time.Now())
+		<-readerDone
+		_ = in.SetReadDeadline(time.Time{})
+	}()
+
+	sc := bufio.NewScanner(rec)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	if !sc.Scan() {
+		return fmt.Errorf("recording is empty")
+	}
+	var header castHeader
+	if err := json.Unmarshal(sc.Bytes(), &header); err != nil || header.Version != 2 {
+		return fmt.Errorf("recording is not asciicast v2")
+	}
+
+	last := 0.0
+	for sc.Scan() {
+		var ev []json.RawMessage
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || len(ev) != 3 {
+			continue
+		}
+		var at float64
+		var code, data string
+		if json.Unmarshal(ev[0], &at) != nil || json.Unmarshal(ev[1], &code) != nil || json.Unmarshal(ev[2], &data) != nil {
+			continue
+		}
+		wait := time.Duration((at - last) / speed * float64(time.Second))
+		last = at
+		if wait > maxReplayIdle {
+			wait = maxReplayIdle
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		if code == "o" {
+			io.WriteString(out, data)
+		}
+	}
+	return sc.Err()
+}
+
+// parseReplayArgs reads `replay <id> [speed]`.
+func parseReplayArgs(args []string) (string, float64, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return "", 0, fmt.Errorf("usage: replay <id> [speed]")
+	}
+	speed := 1.0
+	if len(args) == 2 {
+		v, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || v <= 0 {
+			return "", 0, fmt.Errorf("invalid speed %q", args[1])
+		}
+		speed = v
+	}
+	return args[0], speed, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRecordingsDir points recordings at a fresh directory for one test.
+func useRecordingsDir(t *testing.T) {
+	t.Helper()
+	dir, maxBytes, retention := recordingsDir, maxRecordingBytes, recordingRetention
+	t.Cleanup(func() { recordingsDir, maxRecordingBytes, recordingRetention = dir, maxBytes, retention })
+	recordingsDir = t.TempDir()
+}
+
+// readCast returns the header and events of recording id.
+func readCast(t *testing.T, id string) (castHeader, [][]any) {
+	t.Helper()
+	data, err := os.ReadFile(recordingPath(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	var header castHeader
+	if !sc.Scan() || json.Unmarshal(sc.Bytes(), &header) != nil {
+		t.Fatalf("recording has no valid header: %q", data)
+	}
+	var events [][]any
+	for sc.Scan() {
+		var ev []any
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || len(ev) != 3 {
+			t.Fatalf("invalid event line %q", sc.Text())
+		}
+		events = append(events, ev)
+	}
+	return header, events
+}
+
+func TestCastRecorder(t *testing.T) {
+	useRecordingsDir(t)
+	size := newSizeSource()
+	size.set(100, 30)
+	id := newSessionID()
+	rec, err := newCastRecorder(id, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.output([]byte("hello\r\n"))
+	rec.resize(termSize{Cols: 120, Rows: 40})
+	rec.output([]byte("\033[1mé\033[0m"))
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	header, events := readCast(t, id)
+	if header.Version != 2 || header.Width != 100 || header.Height != 30 {
+		t.Errorf("header = %+v, want version 2 at 100x30", header)
+	}
+	want := [][2]string{{"o", "hello\r\n"}, {"r", "120x40"}, {"o", "\033[1mé\033[0m"}}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	last := 0.0
+	for i, ev := range events {
+		if ev[1] != want[i][0] || ev[2] != want[i][1] {
+			t.Errorf("event %d = %q %q, want %q %q", i, ev[1], ev[2], want[i][0], want[i][1])
+		}
+		if at := ev[0].(float64); at < last {
+			t.Errorf("event %d goes back in time", i)
+		} else {
+			last = at
+		}
+	}
+}
+
+func TestCastRecorderTruncates(t *testing.T) {
+	useRecordingsDir(t)
+	maxRecordingBytes = 1024
+	id := newSessionID()
+	rec, err := newCastRecorder(id, newSizeSource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		rec.output([]byte(strings.Repeat("x", 50)))
+	}
+	rec.Close()
+
+	fi, err := os.Stat(recordingPath(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() > maxRecordingBytes+int64(len(truncatedNotice))+32 {
+		t.Errorf("recording is %d bytes, over the %d byte limit", fi.Size(), maxRecordingBytes)
+	}
+	_, events := readCast(t, id)
+	if last := events[len(events)-1]; last[2] != truncatedNotice {
+		t.Errorf("last event is %q, want the truncation notice", last[2])
+	}
+}
+
+func TestPruneRecordings(t *testing.T) {
+	useRecordingsDir(t)
+	recordingRetention = time.Hour
+	old, fresh := newSessionID(), newSessionID()
+	other := filepath.Join(recordingsDir, "notes.txt")
+	for _, path := range []string{recordingPath(old), recordingPath(fresh), other} {
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	past := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{recordingPath(old), other} {
+		if err := os.Chtimes(path, past, past); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruneRecordings()
+	for path, want := range map[string]bool{recordingPath(old): false, recordingPath(fresh): true, other: true} {
+		if _, err := os.Stat(path); (err == nil) != want {
+			t.Errorf("%s exists = %t, want %t", filepath.Base(path), err == nil, want)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	cast := `{"version":2,"width":80,"height":24}
+[0.01,"o","one "]
+[0.02,"r","100x30"]
+not an event
+[0.03,"o","two"]
+`
+	var out bytes.Buffer
+	in := newInputPipe(context.Background())
+	if err := replay(context.Background(), in, &out, strings.NewReader(cast), 10); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "one two" {
+		t.Errorf("replayed %q, want %q", out.String(), "one two")
+	}
+
+	// The pipe must be usable again once the replay is over
+	in.Write([]byte("x"))
+	buf := make([]byte, 1)
+	if n, err := in.Read(buf); n != 1 || err != nil {
+		t.Errorf("Read after replay = %d, %v", n, err)
+	}
+}
+
+func TestReplayKeyStops(t *testing.T) {
+	cast := `{"version":2,"width":80,"height":24}
+[0.0,"o","first"]
+[1.5,"o","second"]
+`
+	var out bytes.Buffer
+	in := newInputPipe(context.Background())
+	time.AfterFunc(100*time.Millisecond, func() { in.Write([]byte("q")) })
+	start := time.Now()
+	if err := replay(context.Background(), in, &out, strings.NewReader(cast), 1); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("a key press didn't stop the replay")
+	}
+	if out.String() != "first" {
+		t.Errorf("replayed %q, want only the output before the key press", out.String())
+	}
+}
+
+func TestReplayRejects(t *testing.T) {
+	in := newInputPipe(context.Background())
+	for _, cast := range []string{"", `{"version":1}` + "\n", "garbage\n"} {
+		if err := replay(context.Background(), in, &bytes.Buffer{}, strings.NewReader(cast), 1); err == nil {
+			t.Errorf("replay(%q) succeeded", cast)
+		}
+	}
+}
+
+func TestHandleRecording(t *testing.T) {
+	useRecordingsDir(t)
+	id := newSessionID()
+	if err := os.WriteFile(recordingPath(id), []byte(`{"version":2}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /recordings/{id}", handleRecording)
+
+	tests := []struct {
+		id   string
+		want int
+	}{
+		{id, http.StatusOK},
+		{newSessionID(), http.StatusNotFound},
+		{"..%2Fsecret", http.StatusNotFound},
+		{"ABCDEF0123456789", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/recordings/"+tt.id, nil))
+		if w.Code != tt.want {
+			t.Errorf("GET /recordings/%s = %d, want %d", tt.id, w.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && w.Header().Get("Content-Type") != "application/x-asciicast" {
+			t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestParseReplayArgs(t *testing.T) {
+	tests := []struct {
+		args      []string
+		wantSpeed float64
+		wantErr   bool
+	}{
+		{[]string{"0123456789abcdef"}, 1, false},
+		{[]string{"0123456789abcdef", "2.5"}, 2.5, false},
+		{nil, 0, true},
+		{[]string{"0123456789abcdef", "0"}, 0, true},
+		{[]string{"0123456789abcdef", "fast"}, 0, true},
+		{[]string{"a", "1", "2"}, 0, true},
+	}
+	for _, tt := range tests {
+		_, speed, err := parseReplayArgs(tt.args)
+		if (err != nil) != tt.wantErr || speed != tt.wantSpeed {
+			t.Errorf("parseReplayArgs(%q) = %v, %v", tt.args, speed, err)
+		}
+	}
+}
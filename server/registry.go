@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// shutdownNotice is shown in every terminal when the server shuts down.
+const shutdownNotice = "Server restarting, please reconnect in a moment"
+
+// shutdownNoticeDelay is how long visitors get to read the notice before
+// their sessions are ended.
+const shutdownNoticeDelay = time.Second
+
+// sessionRegistry tracks the running sessions, so a reconnecting client can
+// reattach, a spectator can watch and shutdown can reach all of them.
+type sessionRegistry struct {
+	mu      sync.Mutex
+	byToken map[string]*session
+	byID    map[string]*session
+	closing bool
+}
+
+// sessions holds every running session.
+var sessions = &sessionRegistry{
+	byToken: make(map[string]*session),
+	byID:    make(map[string]*session),
+}
+
+// transports counts the connection handlers still running, so shutdown can
+// wait for them to send their close messages.
+var transports sync.WaitGroup
+
+// add registers a session. It returns false once shutdown has begun.
+func (r *sessionRegistry) add(s *session) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closing {
+		return false
+	}
+	r.byToken[s.token] = s
+	r.byID[s.id] = s
+	return true
+}
+
+func (r *sessionRegistry) remove(s *session) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byToken, s.token)
+	delete(r.byID, s.id)
+}
+
+// lookup returns the live session with the given id, or nil.
+func (r *sessionRegistry) lookup(id string) *session {
+	r.mu.Lock()
+	s := r.byID[id]
+	r.mu.Unlock()
+	if s == nil || s.ctx.Err() != nil {
+		return nil
+	}
+	return s
+}
+
+// resume returns the live session with the given token, or nil.
+func (r *sessionRegistry) resume(token string) *session {
+	if token == "" {
+		return nil
+	}
+	r.mu.Lock()
+	s := r.byToken[token]
+	r.mu.Unlock()
+	if s == nil || s.ctx.Err() != nil {
+		return nil
+	}
+	return s
+}
+
+// openSession reattaches to the running session holding token, or starts a
+// new one if there is none and admission allows it. resumed reports which.
+// Either way the session takes caps as its terminal's capabilities.
+func openSession(token string, caps capabilities, ip, frontend string) (sess *session, resumed bool, err error) {
+	if sess = sessions.resume(token); sess != nil {
+		sess.setCaps(caps)
+		return sess, true, nil
+	}
+	release, err := admission.admit(ip, frontend)
+	if err != nil {
+		return nil, false, err
+	}
+	sess = newSession(context.Background(), nil)
+	sess.visitor, sess.frontend = journal.visitor(ip), frontend
+	sess.setCaps(caps)
+	go func() {
+		defer release()
+		sess.run()
+	}()
+	return sess, false, nil
+}
+
+// shutdown shows shutdownNotice in every session, ends them all and waits
+// for them to finish, or for ctx to end. No sessions can be added afterwards.
+func (r *sessionRegistry) shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.closing = true
+	all := make([]*session, 0, len(r.byID))
+	for _, s := range r.byID {
+		all = append(all, s)
+	}
+	r.mu.Unlock()
+	if len(all) == 0 {
+		return nil
+	}
+
+	for _, s := range all {
+		s.statusLine(shutdownNotice)
+	}
+	select {
+	case <-time.After(shutdownNoticeDelay):
+	case <-ctx.Done():
+	}
+	for _, s := range all {
+		s.stop(shutdownNotice)
+	}
+	for _, s := range all {
+		select {
+		case <-s.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
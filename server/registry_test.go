@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// transportRecorder is a transport that collects what a session sends it.
+type transportRecorder struct {
+	mu      sync.Mutex
+	out     []byte
+	control []string
+}
+
+func (r *transportRecorder) send(b []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out = append(r.out, b...)
+	return nil
+}
+
+func (r *transportRecorder) sendControl(typ string, v any) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.control = append(r.control, typ)
+	return nil
+}
+
+func (r *transportRecorder) output() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return string(r.out)
+}
+
+func TestRegistryResume(t *testing.T) {
+	r := &sessionRegistry{byToken: make(map[string]*session), byID: make(map[string]*session)}
+	s := newSession(context.Background(), nil)
+	if !r.add(s) {
+		t.Fatal("add refused a session")
+	}
+	if got := r.resume(s.token); got != s {
+		t.Error("resume didn't find the session by its token")
+	}
+	if got := r.lookup(s.id); got != s {
+		t.Error("lookup didn't find the session by its id")
+	}
+	for _, token := range []string{"", s.id, "0123456789abcdef0123456789abcdef"} {
+		if r.resume(token) != nil {
+			t.Errorf("resume(%q) found a session", token)
+		}
+	}
+
+	s.close()
+	if r.resume(s.token) != nil || r.lookup(s.id) != nil {
+		t.Error("an ended session can still be resumed")
+	}
+	r.remove(s)
+	if len(r.byToken) != 0 || len(r.byID) != 0 {
+		t.Error("remove left the session registered")
+	}
+}
+
+func TestRegistryClosing(t *testing.T) {
+	r := &sessionRegistry{byToken: make(map[string]*session), byID: make(map[string]*session)}
+	if err := r.shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.add(newSession(context.Background(), nil)) {
+		t.Error("add accepted a session after shutdown")
+	}
+}
+
+func TestOpenSessionResumes(t *testing.T) {
+	s := newSession(context.Background(), nil)
+	if !sessions.add(s) {
+		t.Fatal("add refused a session")
+	}
+	t.Cleanup(func() { sessions.remove(s) })
+
+	caps := capabilities{Colors: 256, Unicode: true}
+	got, resumed, err := openSession(s.token, caps, "192.0.2.1", "ws")
+	if err != nil || got != s || !resumed {
+		t.Fatalf("openSession = %p, %t, %v; want the session resumed", got, resumed, err)
+	}
+	if s.caps().Colors != 256 {
+		t.Error("resuming didn't take the new terminal's capabilities")
+	}
+}
+
+func TestSessionReattach(t *testing.T) {
+	grace := resumeGrace
+	resumeGrace = time.Hour
+	t.Cleanup(func() { resumeGrace = grace })
+
+	s := newSession(context.Background(), nil)
+	defer s.close()
+	s.deliver([]byte("\033[2Jwelcome\r\n"))
+
+	var first transportRecorder
+	firstAttached := s.attach(first.send, first.sendControl)
+	s.deliver([]byte("shell$ "))
+	if got := first.output(); got != "\033[2Jwelcome\r\nshell$ " {
+		t.Errorf("first transport got %q", got)
+	}
+
+	// The client drops and reconnects
+	s.detach(firstAttached)
+	s.deliver([]byte("ls"))
+	var second transportRecorder
+	secondAttached := s.attach(second.send, second.sendControl)
+	if got := second.output(); got != "\033[2Jwelcome\r\nshell$ ls" {
+		t.Errorf("reattached transport got %q, want a redraw of the screen", got)
+	}
+	if got := first.output(); got != "\033[2Jwelcome\r\nshell$ " {
+		t.Errorf("detached transport got output: %q", got)
+	}
+
+	// A stale detach from the first connection leaves the second attached
+	s.detach(firstAttached)
+	s.deliver([]byte("\r\n"))
+	if got := second.output(); got != "\033[2Jwelcome\r\nshell$ ls\r\n" {
+		t.Errorf("stale detach cut off the current transport: %q", got)
+	}
+
+	// Attaching again replaces the second transport
+	var third transportRecorder
+	s.attach(third.send, third.sendControl)
+	select {
+	case <-secondAttached:
+	default:
+		t.Error("replaced transport wasn't told")
+	}
+	if s.ctx.Err() != nil {
+		t.Error("the session ended while a client could still resume it")
+	}
+}
+
+func TestSessionResumeGraceExpires(t *testing.T) {
+	grace := resumeGrace
+	resumeGrace = 20 * time.Millisecond
+	t.Cleanup(func() { resumeGrace = grace })
+
+	s := newSession(context.Background(), nil)
+	var tr transportRecorder
+	s.detach(s.attach(tr.send, tr.sendControl))
+	select {
+	case <-s.ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("the session outlived its resume grace")
+	}
+	if s.reason() != "session expired" {
+		t.Errorf("reason = %q, want %q", s.reason(), "session expired")
+	}
+}
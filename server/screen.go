@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"sync"
+)
+
+// screenLogMax caps each of the screen log's buffers. Programs that redraw
+// without ever clearing the screen lose their oldest output past this point.
+const screenLogMax = 256 << 10
+
+var (
+	seqClear      = []byte("\x1b[2J")
+	seqHome       = []byte("\x1b[H")
+	seqAltEnter   = []byte("\x1b[?1049h")
+	seqAltLeave   = []byte("\x1b[?1049l")
+	seqHideCursor = []byte("\x1b[?25l")
+	seqShowCursor = []byte("\x1b[?25h")
+)
+
+// screenLog keeps enough of a session's output to redraw its terminal from
+// scratch: everything written since the screen was last cleared, tracked
+// separately for the main and the alternate screen. Writing snapshot to a
+// freshly reset terminal reproduces what the visitor was looking at.
+type screenLog struct {
+	mu           sync.Mutex
+	main         []byte
+	alt          []byte
+	inAlt        bool
+	cursorHidden bool
+}
+
+// write records a chunk of output. Chunks come from the output pump, which
+// never splits an escape sequence, so sequences can be matched within one.
+func (l *screenLog) write(b []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, 0x1b)
+		if i < 0 {
+			l.append(b)
+			return
+		}
+		l.append(b[:i])
+		b = b[i:]
+		n := escapeLen(b)
+		if n == 0 {
+			n = len(b)
+		}
+		seq := b[:n]
+		b = b[n:]
+
+		switch {
+		case bytes.Equal(seq, seqAltEnter):
+			// The snapshot re-enters the alternate screen itself
+			l.inAlt = true
+			l.alt = l.alt[:0]
+			continue
+		case bytes.Equal(seq, seqAltLeave):
+			l.inAlt = false
+			l.alt = l.alt[:0]
+			continue
+		case bytes.Equal(seq, seqClear):
+			// Nothing before a clear is visible any more
+			cur := l.current()
+			home := bytes.HasSuffix(*cur, seqHome)
+			*cur = (*cur)[:0]
+			if home {
+				*cur = append(*cur, seqHome...)
+			}
+		case bytes.Equal(seq, seqHideCursor):
+			l.cursorHidden = true
+		case bytes.Equal(seq, seqShowCursor):
+			l.cursorHidden = false
+		}
+		l.append(seq)
+	}
+}
+
+// current returns the buffer of the screen being drawn on.
+func (l *screenLog) current() *[]byte {
+	if l.inAlt {
+		return &l.alt
+	}
+	return &l.main
+}
+
+func (l *screenLog) append(b []byte) {
+	cur := l.current()
+	*cur = append(*cur, b...)
+	if len(*cur) <= screenLogMax {
+		return
+	}
+	// Drop the older half, restarting at a line boundary so no rune or
+	// escape sequence is cut
+	drop := len(*cur) - screenLogMax/2
+	if nl := bytes.IndexByte((*cur)[drop:], '\n'); nl >= 0 {
+		drop += nl + 1
+	}
+	*cur = append((*cur)[:0], (*cur)[drop:]...)
+}
+
+// snapshot returns output that redraws the current screen on a reset terminal.
+func (l *screenLog) snapshot() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]byte, 0, len(l.main)+len(l.alt)+32)
+	out = append(out, l.main...)
+	if l.inAlt {
+		out = append(out, seqAltEnter...)
+		out = append(out, l.alt...)
+	}
+	if l.cursorHidden {
+		out = append(out, seqHideCursor...)
+	}
+	return out
+}
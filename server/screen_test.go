@@ -0,0 +1,86 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreenLogSnapshot(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"plain", []string{"a", "b\r\n"}, "ab\r\n"},
+		{"clear drops what came before", []string{"old\r\n", "\033[2Jnew"}, "\033[2Jnew"},
+		{"clear keeps the home before it", []string{"old\033[H", "\033[2Jnew"}, "\033[H\033[2Jnew"},
+		{"other sequences are kept", []string{"\033[1mbold\033[0m"}, "\033[1mbold\033[0m"},
+		{"alternate screen", []string{"shell$ ", "\033[?1049h", "game"}, "shell$ \033[?1049hgame"},
+		{"alternate screen cleared", []string{"shell$ \033[?1049hgame", "\033[2Jframe"}, "shell$ \033[?1049h\033[2Jframe"},
+		{"back to the main screen", []string{"shell$ \033[?1049hgame\033[?1049l", "\r\n"}, "shell$ \r\n"},
+		{"alternate screen entered again", []string{"\033[?1049hone\033[?1049l\033[?1049htwo"}, "\033[?1049htwo"},
+		{"hidden cursor", []string{"\033[?25lx"}, "\033[?25lx\033[?25l"},
+		{"shown cursor", []string{"\033[?25lx\033[?25h"}, "\033[?25lx\033[?25h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l screenLog
+			for _, w := range tt.writes {
+				l.write([]byte(w))
+			}
+			if got := string(l.snapshot()); got != tt.want {
+				t.Errorf("snapshot = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// A resumed client gets the shell history up to the game, then the game's
+// latest frame on the alternate screen, with the cursor still hidden.
+func TestScreenLogSnapshotResume(t *testing.T) {
+	var l screenLog
+	l.write([]byte("\033[H\033[2Jwelcome\r\nshell$ snake\r\n"))
+	l.write([]byte("\033[?1049h\033[?25l\033[2J\033[1;1Hframe 1"))
+	l.write([]byte("\033[2J\033[1;1Hframe 2"))
+
+	want := "\033[H\033[2Jwelcome\r\nshell$ snake\r\n" +
+		"\033[?1049h\033[2J\033[1;1Hframe 2" +
+		"\033[?25l"
+	if got := string(l.snapshot()); got != want {
+		t.Errorf("snapshot = %q, want %q", got, want)
+	}
+}
+
+func TestScreenLogCap(t *testing.T) {
+	var l screenLog
+	line := strings.Repeat("x", 99) + "\n"
+	for i := 0; i < 2*screenLogMax/len(line); i++ {
+		l.write([]byte(line))
+	}
+	l.write([]byte("last"))
+
+	snap := l.snapshot()
+	if len(snap) > screenLogMax {
+		t.Errorf("snapshot is %d bytes, over the %d byte cap", len(snap), screenLogMax)
+	}
+	if len(snap) < screenLogMax/2 {
+		t.Errorf("snapshot is %d bytes, trimmed more than half", len(snap))
+	}
+	if !strings.HasPrefix(string(snap), line) || !strings.HasSuffix(string(snap), "\nlast") {
+		t.Error("trimmed snapshot doesn't start at a line and end with the latest output")
+	}
+}
+
+func TestScreenLogCapPerScreen(t *testing.T) {
+	var l screenLog
+	l.write([]byte("shell$ "))
+	l.write([]byte("\033[?1049h"))
+	big := []byte(strings.Repeat("y", 99) + "\n")
+	for i := 0; i < 2*screenLogMax/len(big); i++ {
+		l.write(big)
+	}
+	l.write([]byte("\033[?1049l"))
+	if got := string(l.snapshot()); got != "shell$ " {
+		t.Errorf("after leaving the alternate screen, snapshot = %q", got)
+	}
+}
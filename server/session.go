@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// session is one visitor's terminal, independent of the transport carrying
+// it. Transports feed keystrokes through input, report sizes to size and
+// deliver whatever the session's output pump hands them. A session outlives
+// its transport for resumeGrace after detach, so a client holding its token
+// can attach again.
+type session struct {
+	id     string
+	token  string // secret that lets a client reattach
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // closed once run has returned
+	in     *inputPipe
+	out    io.Writer
+	pump   *outputPump
+	size   *sizeSource
+	screen *screenLog
+	logger *ConsoleLogger
+	prompt promptLine // the shell's input line, for announcements
+
+	// Who connected and how, for the journal. Set before run.
+	visitor  string
+	frontend string
+
+	lastInput atomic.Int64 // unix nanoseconds of the last keystroke
+
+	// sendMu keeps writes to the transport in order, so output delivered
+	// while a transport attaches can't overtake its screen redraw. It is
+	// taken before mu and held while writing, which mu never is.
+	sendMu sync.Mutex
+
+	mu        sync.Mutex
+	endCode   int
+	endReason string
+	onStop    func()                        // run by shutdown before the session ends
+	sink      func([]byte) error            // current transport, nil while detached
+	control   func(typ string, v any) error // its control channel, if it has one
+	attached  chan struct{}                 // closed when the current transport is replaced
+	grace     *time.Timer
+	viewers   map[*viewer]struct{}
+	termCaps  capabilities // what the visitor's terminal can display
+}
+
+var (
+	// idleTimeout ends sessions without input for this long (0 disables).
+	idleTimeout = 15 * time.Minute
+	// idleWarning is how long before the idle timeout a countdown is shown.
+	idleWarning = time.Minute
+	// maxSessionDuration ends every session after this long (0 disables).
+	maxSessionDuration = 2 * time.Hour
+	// resumeGrace is how long a detached session waits for its client to
+	// reconnect (0 ends it right away).
+	resumeGrace = 2 * time.Minute
+)
+
+// newSessionID returns a random identifier for a session. It also names the
+// session's recording.
+func newSessionID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// newSession creates a session whose output is sent with send, which may be
+// nil if a transport attaches later. The session ends when parent does, when
+// close is called or when its programs finish.
+func newSession(parent context.Context, send func([]byte) error) *session {
+	ctx, cancel := context.WithCancel(parent)
+	id := newSessionID()
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	s := &session{
+		id:       id,
+		token:    hex.EncodeToString(token),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		in:       newInputPipe(ctx),
+		size:     newSizeSource(),
+		screen:   &screenLog{},
+		logger:   newConsoleLogger(id),
+		sink:     send,
+		attached: make(chan struct{}),
+		viewers:  make(map[*viewer]struct{}),
+	}
+	s.pump = newOutputPump(s.deliver)
+	s.out = s.pump
+	s.lastInput.Store(time.Now().UnixNano())
+	return s
+}
+
+// deliver is the output pump's send function. Output is kept in the screen
+// log and passed on to the attached transport, if any, and to spectators.
+// Transport errors are not returned: a dropped connection detaches, it
+// doesn't stop the session. The transport is written to without s.mu held,
+// so a stalled client can't block detach, attach or shutdown.
+func (s *session) deliver(b []byte) error {
+	metrics.bytesOut.add(uint64(len(b)))
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	s.screen.write(b)
+	sink, control := s.sink, s.control
+	dropped := s.feedViewers(b)
+	viewers := len(s.viewers)
+	s.mu.Unlock()
+
+	if sink != nil {
+		_ = sink(b)
+	}
+	if control != nil {
+		if dropped {
+			_ = control(msgViewers, viewersMsg{Count: viewers})
+		}
+		if rangBell(b) {
+			_ = control(msgBell, nil)
+		}
+	}
+	return nil
+}
+
+// attach makes send the session's transport, replacing any other, and sends
+// it the current screen. control, if not nil, carries control messages such
+// as the viewer count. The returned channel is closed when another transport
+// attaches in its place.
+func (s *session) attach(send func([]byte) error, control func(typ string, v any) error) <-chan struct{} {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	s.mu.Lock()
+	if s.grace != nil {
+		s.grace.Stop()
+		s.grace = nil
+	}
+	close(s.attached)
+	s.attached = make(chan struct{})
+	attached := s.attached
+	s.sink = send
+	s.control = control
+	snap := s.screen.snapshot()
+	viewers := len(s.viewers)
+	s.mu.Unlock()
+
+	if len(snap) > 0 {
+		_ = send(snap)
+	}
+	if viewers > 0 && control != nil {
+		_ = control(msgViewers, viewersMsg{Count: viewers})
+	}
+	return attached
+}
+
+// detach drops the transport that attached with the given channel and gives
+// the client resumeGrace to come back before the session ends. It does
+// nothing if another transport has attached since.
+func (s *session) detach(attached <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attached != attached {
+		return
+	}
+	s.sink = nil
+	s.control = nil
+	if resumeGrace <= 0 {
+		s.close()
+		return
+	}
+	s.grace = time.AfterFunc(resumeGrace, func() {
+		s.end("session expired")
+	})
+}
+
+// input passes keystrokes from the transport to the running program.
+func (s *session) input(data []byte) {
+	s.lastInput.Store(time.Now().UnixNano())
+	metrics.bytesIn.add(uint64(len(data)))
+	// translate CR to LF so both CLI and Bubble Tea see newlines
+	s.in.Write([]byte(strings.ReplaceAll(string(data), "\r", "\n")))
+}
+
+// handleControl acts on a control message from the client. reply sends the
+// answer to a ping. It reports false once the client has closed the session.
+func (s *session) handleControl(env envelope, reply func(typ string, v any) error) bool {
+	switch env.Type {
+	case msgInput:
+		var in inputMsg
+		if err := env.decodePayload(&in); err != nil {
+			s.logger.LogError(err.Error())
+			break
+		}
+		s.input([]byte(in.Data))
+	case msgResize:
+		var rm resizeMsg
+		if err := env.decodePayload(&rm); err != nil {
+			s.logger.LogError(err.Error())
+			break
+		}
+		s.size.set(rm.Cols, rm.Rows)
+	case msgPing:
+		var pm pingMsg
+		_ = env.decodePayload(&pm)
+		_ = reply(msgPong, pm)
+	case msgPong:
+	case msgClose:
+		// The client ended the session on purpose
+		s.close()
+		return false
+	default:
+		s.logger.LogDebug("Ignoring unknown message type: " + env.Type)
+	}
+	return true
+}
+
+// sendControl sends a control message to the attached transport, if it has
+// a control channel.
+func (s *session) sendControl(typ string, v any) error {
+	s.mu.Lock()
+	control := s.control
+	s.mu.Unlock()
+	if control == nil {
+		return nil
+	}
+	return control(typ, v)
+}
+
+// caps returns the capabilities of the visitor's terminal. Until a transport
+// reports them, the session assumes plain ASCII without colors.
+func (s *session) caps() capabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.termCaps
+}
+
+// setCaps records the capabilities of the visitor's terminal. A client that
+// resumes the session from another terminal reports its own.
+func (s *session) setCaps(c capabilities) {
+	s.mu.Lock()
+	s.termCaps = c
+	s.mu.Unlock()
+	s.logger.LogDebug("Terminal capabilities: " + c.String())
+}
+
+// close ends the session; run returns once its programs have stopped.
+func (s *session) close() {
+	s.in.Close()
+	s.cancel()
+}
+
+// end closes the session and records why, for the transport to tell the visitor.
+func (s *session) end(reason string) {
+	s.endWithCode(closeNormal, reason)
+}
+
+// endWithCode is end with a close code other than closeNormal.
+func (s *session) endWithCode(code int, reason string) {
+	s.mu.Lock()
+	if s.endReason == "" {
+		s.endCode, s.endReason = code, reason
+	}
+	s.mu.Unlock()
+	s.close()
+}
+
+// reason returns why the session was ended by the server, or "" if it wasn't.
+func (s *session) reason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.endReason
+}
+
+// closeCode returns the close code for the transport to send.
+func (s *session) closeCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.endCode == 0 {
+		return closeNormal
+	}
+	return s.endCode
+}
+
+// setOnStop registers fn to run when the server shuts down while fn is set,
+// e.g. to save a game in progress. Pass nil to clear it.
+func (s *session) setOnStop(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onStop = fn
+}
+
+// stop runs the session's onStop hook and ends it because the server is
+// going away.
+func (s *session) stop(reason string) {
+	s.mu.Lock()
+	fn := s.onStop
+	s.onStop = nil
+	s.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+	s.endWithCode(closeServiceRestart, reason)
+}
+
+// statusLine writes msg over the top terminal row and puts the cursor back,
+// leaving the running program's screen otherwise untouched. An empty msg
+// clears the row.
+func (s *session) statusLine(msg string) {
+	if msg != "" {
+		msg = "\033[7m " + msg + " \033[0m"
+	}
+	fmt.Fprintf(s.out, "\0337\033[1;1H\033[2K%s\0338", msg)
+}
+
+// watchdog ends the session once it has been idle for idleTimeout, counting
+// down in the terminal for the last idleWarning, or once it is older than
+// maxSessionDuration.
+func (s *session) watchdog() {
+	started := time.Now()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	warned := false
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if maxSessionDuration > 0 && time.Since(started) >= maxSessionDuration {
+			s.end("maximum session length reached")
+			return
+		}
+		if idleTimeout <= 0 {
+			continue
+		}
+		idle := time.Since(time.Unix(0, s.lastInput.Load()))
+		if idle >= idleTimeout {
+			s.end("disconnected after being idle")
+			return
+		}
+		if left := idleTimeout - idle; left <= idleWarning {
+			s.statusLine(fmt.Sprintf("Idle: disconnecting in %ds, press any key to stay", int(left.Round(time.Second).Seconds())))
+			warned = true
+		} else if warned {
+			s.statusLine("")
+			warned = false
+		}
+	}
+}
+
+// run starts the output pump (and recording, if enabled), runs the session's
+// programs and returns after the remaining output has been sent.
+func (s *session) run() {
+	defer close(s.done)
+	defer s.close()
+
+	if !sessions.add(s) {
+		s.endWithCode(closeServiceRestart, shutdownNotice)
+		return
+	}
+	defer sessions.remove(s)
+
+	started := time.Now()
+	metrics.sessionsTotal.inc()
+	metrics.sessionsActive.inc()
+	s.record(journalEvent{Event: eventConnect, Visitor: s.visitor, Frontend: s.frontend})
+	defer func() {
+		metrics.sessionsActive.dec()
+		metrics.sessionDuration.observe(time.Since(started).Seconds())
+		s.record(journalEvent{Event: eventDisconnect, Duration: time.Since(started).Seconds(), Reason: s.reason()})
+	}()
+
+	if recordingsDir != "" {
+		if rec, err := newCastRecorder(s.id, s.size); err != nil {
+			s.logger.Error("recording failed", "err", err)
+		} else {
+			defer rec.Close()
+			s.pump.tap = rec.output
+			stopResize := s.size.forward(rec.resize)
+			defer stopResize()
+			s.logger.LogInfo("Recording session " + s.id)
+		}
+	}
+
+	pumpDone := make(chan struct{})
+	go func() {
+		defer close(pumpDone)
+		s.pump.run(s.ctx)
+	}()
+	// Flush remaining output before the transport is closed
+	defer func() {
+		s.cancel()
+		<-pumpDone
+	}()
+
+	go s.watchdog()
+	s.programs()
+}
+
+// programs runs the CLI first, then Bubble Tea. It returns when both are
+// done or the session context ends.
+func (s *session) programs() {
+	cli(s)
+	if s.ctx.Err() != nil {
+		return
+	}
+	s.runTea()
+}
@@ -0,0 +1,94 @@
+package main
+
+import "sync"
+
+// termSize is a terminal size in character cells.
+type termSize struct {
+	Cols int
+	Rows int
+}
+
+// sizeSource holds a session's last known terminal size and fans updates out
+// to every program that subscribes. Subscribers that start late still receive
+// the current size right away.
+type sizeSource struct {
+	mu    sync.Mutex
+	cur   termSize
+	known bool
+	subs  map[chan termSize]struct{}
+}
+
+func newSizeSource() *sizeSource {
+	return &sizeSource{subs: make(map[chan termSize]struct{})}
+}
+
+// set records a new size and notifies subscribers. Non-positive sizes are ignored.
+func (s *sizeSource) set(cols, rows int) {
+	if cols <= 0 || rows <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cur = termSize{Cols: cols, Rows: rows}
+	s.known = true
+	for ch := range s.subs {
+		offerSize(ch, s.cur)
+	}
+}
+
+// get returns the last known size and whether one was ever reported.
+func (s *sizeSource) get() (termSize, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cur, s.known
+}
+
+// subscribe returns a channel that receives the current size (if known) and
+// every later change. Slow readers only see the latest size. The returned
+// function unsubscribes and closes the channel.
+func (s *sizeSource) subscribe() (<-chan termSize, func()) {
+	ch := make(chan termSize, 1)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	if s.known {
+		ch <- s.cur
+	}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			close(ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+// forward calls fn from its own goroutine for the current size and every
+// change until the returned stop function is called. stop waits for a
+// running fn to return.
+func (s *sizeSource) forward(fn func(termSize)) (stop func()) {
+	ch, unsubscribe := s.subscribe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for sz := range ch {
+			fn(sz)
+		}
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+// offerSize replaces any undelivered size in ch with sz.
+func offerSize(ch chan termSize, sz termSize) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- sz
+}
@@ -4,8 +4,9 @@ import tl "github.com/JoelOtter/termloop"
 
 // NewArena will create a new arena with the arena with and arena height given when this function was called in game.go
 // This function will create a arena using the arena struct that can be found in the types.go file.
-func NewArena(w, h int) *Arena {
+func NewArena(g *Game, w, h int) *Arena {
 	arena := new(Arena)
+	arena.game = g
 	// Width and height of the arena are decresed by one to add corners on the arena border.
 	arena.Width = w - 1
 	arena.Height = h - 1
@@ -38,8 +39,10 @@ func (arena *Arena) Contains(c Coordinates) bool {
 func (arena *Arena) Draw(screen *tl.Screen) {
 	// This for loop will range ArenaBorder containing the coordinates of the arenaborder and will print them out on the screen.
 	for i := range arena.ArenaBorder {
-		screen.RenderCell(i.X, i.Y, &tl.Cell{
-			Bg: CheckSelectedColor(counterArena),
-		})
+		cell := &tl.Cell{Bg: CheckSelectedColor(counterArena)}
+		if !arena.game.Display.Colors {
+			cell.Ch = arena.game.Display.block()
+		}
+		screen.RenderCell(i.X, i.Y, cell)
 	}
 }
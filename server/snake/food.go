@@ -18,8 +18,9 @@ var (
 )
 
 // NewFood will create a new piece of food, this will only happen once when the game has started.
-func NewFood() *Food {
+func NewFood(g *Game) *Food {
 	food := new(Food)
+	food.game = g
 	// Create a new entity food with a standard position and 1x1 size
 	food.Entity = tl.NewEntity(1, 1, 1, 1)
 	// Call function MoveFood to move the food to a random position.
@@ -71,10 +72,35 @@ func RandomFood() rune {
 // Draw will print out the food on the screen.
 func (food *Food) Draw(screen *tl.Screen) {
 	screen.RenderCell(food.Foodposition.X, food.Foodposition.Y, &tl.Cell{
-		Ch: food.Emoji,
+		Ch: food.game.Display.foodGlyph(food.Emoji),
 	})
 }
 
+// foodGlyph returns how a kind of food looks on the display. The kinds are
+// Nerd Font glyphs, other terminals get the nearest symbol they can show.
+func (d Display) foodGlyph(kind rune) rune {
+	if d.NerdFont {
+		return kind
+	}
+	switch kind {
+	case FAVOURITE_FOOD:
+		if d.Unicode {
+			return '♥'
+		}
+		return '@'
+	case SPEED_UP_FOOD:
+		if d.Unicode {
+			return '»'
+		}
+		return '!'
+	default:
+		if d.Unicode {
+			return '•'
+		}
+		return 'o'
+	}
+}
+
 // Contains checks if food contains the coordinates, if so this will return a bool.
 func (food *Food) Contains(c Coordinates) bool {
 	return c.X == food.Foodposition.X && c.Y == food.Foodposition.Y
@@ -1,10 +1,11 @@
 package snake
 
 import (
+	"embed"
 	"fmt"
-	"io/ioutil"
-	"log"
+	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	tl "github.com/JoelOtter/termloop"
@@ -13,29 +14,88 @@ import (
 var (
 	DEFAULT_SPEED = 8
 	DEFAULT_FPS   = 60.0
+
+	// HighScoreFile is the markdown table SaveHighScore appends to.
+	HighScoreFile = "HIGHSCORES.md"
+
+	// AssetDir, if set, holds art files that replace the built-in ones.
+	AssetDir = ""
 )
 
-// StartGame will start the game with the tilescreen.
-func StartGame() {
-	sg = tl.NewGame()
-	gs = NewGamescreen()
-	sg.Screen().SetLevel(gs)
-	sg.Screen().SetFps(DEFAULT_FPS)
-	sg.Start()
+// builtinAssets are the game's art files, built into the binary.
+//
+//go:embed assets
+var builtinAssets embed.FS
+
+// assets are the art files the game needs.
+var assets = []string{"gameover-logo.txt"}
+
+// MissingAssets returns the paths of the art files that can't be read from
+// AssetDir. The built-in copies are always there, so nothing is missing
+// while AssetDir is unset.
+func MissingAssets() []string {
+	if AssetDir == "" {
+		return nil
+	}
+	var missing []string
+	for _, name := range assets {
+		path := filepath.Join(AssetDir, name)
+		if _, err := os.Stat(path); err != nil {
+			missing = append(missing, path)
+		}
+	}
+	return missing
+}
+
+// readAsset returns the art file name from AssetDir, or the built-in copy.
+func readAsset(name string) string {
+	if AssetDir != "" {
+		if data, err := os.ReadFile(filepath.Join(AssetDir, name)); err == nil {
+			return string(data)
+		}
+	}
+	data, _ := builtinAssets.ReadFile("assets/" + name)
+	return string(data)
+}
+
+// NewGame will create a game that reads keys from in and draws frames to out.
+func NewGame(in io.Reader, out io.Writer) *Game {
+	g := new(Game)
+	g.sg = tl.NewGame(in, out)
+	return g
+}
+
+// Resize passes the terminal size on to the screen, it is safe to call while the game runs.
+func (g *Game) Resize(w, h int) {
+	g.sg.Resize(w, h)
 }
 
-func NewGamescreen() *Gamescreen {
-	// Creates the gamescreen level and create the entities
-	gs = new(Gamescreen)
+// Start will start the game with the tilescreen and blocks until the player quits.
+func (g *Game) Start() {
+	gs := g.NewGamescreen()
+	g.sg.Screen().SetLevel(gs)
+	g.sg.Screen().SetFps(DEFAULT_FPS)
+	g.sg.SetColors(g.Display.Colors)
+	g.sg.Start()
+}
+
+func (g *Game) NewGamescreen() *Gamescreen {
+	// Creates the gamescreen level and create the entities. The entities
+	// find it through g.gs while they are built; SaveScore may read it from
+	// another goroutine, so it is published under g.mu
+	gs := new(Gamescreen)
 	gs.Level = tl.NewBaseLevel(tl.Cell{
 		Bg: tl.ColorBlack,
 	})
-	gs.Score = 0
-	gs.SnakeEntity = NewSnake()
-	SetDiffiultyFPS()
-	gs.ArenaEntity = NewArena(70, 25)
-	gs.FoodEntity = NewFood()
-	gs.SidepanelObject = NewSidepanel()
+	gs.SnakeEntity = NewSnake(g)
+	g.mu.Lock()
+	g.gs = gs
+	g.SetDiffiultyFPS()
+	g.mu.Unlock()
+	gs.ArenaEntity = NewArena(g, 70, 25)
+	gs.FoodEntity = NewFood(g)
+	gs.SidepanelObject = g.NewSidepanel()
+	sp := gs.SidepanelObject
 
 	// Add entities for the game level.
 	gs.AddEntity(gs.FoodEntity)
@@ -56,15 +116,17 @@ func NewGamescreen() *Gamescreen {
 	}
 
 	// Set Fps and return the screen
-	sg.Screen().SetFps(gs.FPS)
+	g.sg.Screen().SetFps(gs.FPS)
 
 	return gs
 }
 
 // NewSidepanel will create a new sidepanel given the arena height and width.
-func NewSidepanel() *Sidepanel {
+func (g *Game) NewSidepanel() *Sidepanel {
 	// Create a sidepanel and its objects and return it
-	sp = new(Sidepanel)
+	gs := g.gs
+	sp := new(Sidepanel)
+	g.sp = sp
 	sp.Instructions = []string{
 		"Instructions:",
 		"Use ← → ↑ ↓ to move the snake around",
@@ -81,14 +143,18 @@ func NewSidepanel() *Sidepanel {
 	return sp
 }
 
-func Gameover() {
+func (g *Game) Gameover() {
 	// Create a new gameover screen and its content.
+	gs := g.gs
+	if g.OnGameover != nil {
+		g.OnGameover(gs.Score)
+	}
 	gos := new(Gameoverscreen)
+	gos.game = g
 	gos.Level = tl.NewBaseLevel(tl.Cell{
 		Bg: tl.ColorBlack,
 	})
-	logofile, _ := ioutil.ReadFile("util/gameover-logo.txt")
-	gos.Logo = tl.NewEntityFromCanvas(10, 3, tl.CanvasFromString(string(logofile)))
+	gos.Logo = tl.NewEntityFromCanvas(10, 3, tl.CanvasFromString(readAsset("gameover-logo.txt")))
 	gos.Finalstats = []*tl.Text{
 		tl.NewText(10, 13, fmt.Sprintf("Score: %d", gs.Score), tl.ColorWhite, tl.ColorBlack),
 		tl.NewText(10, 15, fmt.Sprintf("Speed: %.0f", gs.FPS), tl.ColorWhite, tl.ColorBlack),
@@ -112,33 +178,52 @@ func Gameover() {
 	}
 
 	// Set the screen
-	sg.Screen().SetLevel(gos)
+	g.sg.Screen().SetLevel(gos)
 }
 
 // UpdateScore updates the score with the given amount of points.
-func UpdateScore(amount int) {
-	gs.Score += amount
-	sp.ScoreText.SetText(fmt.Sprintf("Score: %d", gs.Score))
+func (g *Game) UpdateScore(amount int) {
+	g.mu.Lock()
+	g.gs.Score += amount
+	g.mu.Unlock()
+	g.sp.ScoreText.SetText(fmt.Sprintf("Score: %d", g.gs.Score))
+}
+
+// SaveScore appends the current round's score to the high score table, if
+// anything was scored yet. It is safe to call while the game runs.
+func (g *Game) SaveScore() error {
+	g.mu.Lock()
+	if g.gs == nil || g.gs.Score == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	score, fps := g.gs.Score, g.gs.FPS
+	g.mu.Unlock()
+	return SaveHighScore(score, fps, Difficulty)
 }
 
 // UpdateFPS updates the fps text.
-func UpdateFPS() {
-	sp.SpeedText.SetText(fmt.Sprintf("Speed: %d", gs.SnakeEntity.Speed))
+func (g *Game) UpdateFPS() {
+	g.sp.SpeedText.SetText(fmt.Sprintf("Speed: %d", g.gs.SnakeEntity.Speed))
 }
 
 // RestartGame will restart the game and reset the position of the food and the snake to prevent collision issues.
-func RestartGame() {
+func (g *Game) RestartGame() {
+	gs, sp := g.gs, g.sp
+
 	// Removes the current snake and food from the level.
 	gs.RemoveEntity(gs.SnakeEntity)
 	gs.RemoveEntity(gs.FoodEntity)
 
 	// Generate a new snake and food.
-	gs.SnakeEntity = NewSnake()
-	gs.FoodEntity = NewFood()
+	gs.SnakeEntity = NewSnake(g)
+	gs.FoodEntity = NewFood(g)
 
 	// Revert the score and fps to the standard.
-	SetDiffiultyFPS()
+	g.mu.Lock()
+	g.SetDiffiultyFPS()
 	gs.Score = 0
+	g.mu.Unlock()
 
 	// Update the score and fps text.
 	sp.ScoreText.SetText(fmt.Sprintf("Score: %d", gs.Score))
@@ -147,28 +232,31 @@ func RestartGame() {
 	// Adds the snake and food back and sets them to the standard position.
 	gs.AddEntity(gs.SnakeEntity)
 	gs.AddEntity(gs.FoodEntity)
-	sg.Screen().SetFps(gs.FPS)
-	sg.Screen().SetLevel(gs)
+	g.sg.Screen().SetFps(gs.FPS)
+	g.sg.Screen().SetLevel(gs)
 }
 
-func SetDiffiultyFPS() {
-	gs.FPS = DEFAULT_FPS
-	gs.SnakeEntity.Speed = DEFAULT_SPEED // Movement every 60/8 = 7.5 frames
+func (g *Game) SetDiffiultyFPS() {
+	g.gs.FPS = DEFAULT_FPS
+	g.gs.SnakeEntity.Speed = DEFAULT_SPEED // Movement every 60/8 = 7.5 frames
 }
 
-func SaveHighScore(score int, speed float64, difficulty string) {
+// SaveHighScore appends a row to HIGHSCORES.md, creating the table if needed.
+func SaveHighScore(score int, speed float64, difficulty string) error {
 	var newRow []byte
 	datetime := time.Now()
 	newRow = []byte(fmt.Sprintf("\n|" + fmt.Sprintf("%s", datetime.Format("01-02-2006 15:04:05")) + "|" + fmt.Sprintf("%d", score) + "|" + fmt.Sprintf("%.0f", speed) + "|" + difficulty + "|  "))
-	f, err := os.OpenFile("HIGHSCORES.md", os.O_APPEND|os.O_WRONLY, 0644)
+	f, err := os.OpenFile(HighScoreFile, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
 	if err != nil {
-		log.Fatalf("Error opening file: %s", err)
+		return fmt.Errorf("opening high scores: %w", err)
 	}
+	defer f.Close()
 
-	_, err2 := f.Write(newRow)
-	if err2 != nil {
-		log.Fatalf("Error writing to file: %s", err2)
+	if fi, err := f.Stat(); err == nil && fi.Size() == 0 {
+		newRow = append([]byte("|Date|Score|Speed|Difficulty|\n|---|---|---|---|"), newRow...)
 	}
-
-	f.Close()
+	if _, err := f.Write(newRow); err != nil {
+		return fmt.Errorf("writing high scores: %w", err)
+	}
+	return nil
 }
@@ -1,9 +1,6 @@
 package snake
 
-import (
-	tl "github.com/JoelOtter/termloop"
-	tb "github.com/nsf/termbox-go"
-)
+import tl "github.com/JoelOtter/termloop"
 
 var (
 	counterSnake = 10
@@ -62,10 +59,9 @@ func (snake *Snake) Tick(event tl.Event) {
 func (gos *Gameoverscreen) Tick(event tl.Event) {
 	if event.Type == tl.EventKey {
 		if event.Ch == 'r' {
-			RestartGame()
-		} else if event.Key == tl.KeyDelete {
-			tb.Close()
+			gos.game.RestartGame()
 		}
+		// Delete quits; the termloop frame loop handles that itself
 	}
 }
 
@@ -6,9 +6,10 @@ import (
 	tl "github.com/JoelOtter/termloop"
 )
 
-// NewSnake will create a new snake and is called when the game is initialized.
-func NewSnake() *Snake {
+// NewSnake will create a new snake for the given game and is called when the game is initialized.
+func NewSnake(g *Game) *Snake {
 	snake := new(Snake)
+	snake.game = g
 	snake.Entity = tl.NewEntity(5, 5, 1, 1)
 	snake.Direction = right
 	snake.MovementCounter = 0
@@ -31,12 +32,12 @@ func (snake *Snake) Head() *Coordinates {
 
 // BorderCollision checks if the arena border contains the snakes head, if so it will return true.
 func (snake *Snake) BorderCollision() bool {
-	return gs.ArenaEntity.Contains(*snake.Head())
+	return snake.game.gs.ArenaEntity.Contains(*snake.Head())
 }
 
 // FoodCollision checks if the food contains the snakes head, if so it will return true.
 func (snake *Snake) FoodCollision() bool {
-	return gs.FoodEntity.Contains(*snake.Head())
+	return snake.game.gs.FoodEntity.Contains(*snake.Head())
 }
 
 // SnakeCollision checks if the snakes body contains its head, if so it will return true.
@@ -51,6 +52,9 @@ func isOpposite(a, b direction) bool {
 // Draw will check every tick and draw the snake on the screen, it also checks if the snake has any collisions
 // using the funtions above.
 func (snake *Snake) Draw(screen *tl.Screen) {
+	g := snake.game
+	gs := g.gs
+
 	// Increment movement counter
 	snake.MovementCounter++
 
@@ -102,14 +106,14 @@ func (snake *Snake) Draw(screen *tl.Screen) {
 
 			// Check border collision at prospective position
 			if gs.ArenaEntity.Contains(nHead) {
-				Gameover()
+				g.Gameover()
 				return
 			}
 
 			// Check self-collision at prospective position
 			for i := 0; i < len(snake.Bodylength)-1; i++ {
 				if nHead == snake.Bodylength[i] {
-					Gameover()
+					g.Gameover()
 					return
 				}
 			}
@@ -120,10 +124,10 @@ func (snake *Snake) Draw(screen *tl.Screen) {
 				case FAVOURITE_FOOD:
 					if snake.Speed-3 <= DEFAULT_SPEED {
 						snake.Speed = DEFAULT_SPEED
-						UpdateScore(5)
+						g.UpdateScore(5)
 					} else {
 						snake.Speed -= 3
-						UpdateScore(5)
+						g.UpdateScore(5)
 					}
 					speedChanged = true
 					snake.Bodylength = append(snake.Bodylength, nHead)
@@ -131,7 +135,7 @@ func (snake *Snake) Draw(screen *tl.Screen) {
 					snake.Speed++
 					speedChanged = true
 				default:
-					UpdateScore(1)
+					g.UpdateScore(1)
 					snake.Bodylength = append(snake.Bodylength, nHead)
 				}
 				gs.FoodEntity.MoveFood()
@@ -146,7 +150,7 @@ func (snake *Snake) Draw(screen *tl.Screen) {
 
 		// If speed changed during the tick, update UI and prime the next interval
 		if speedChanged {
-			UpdateFPS()
+			g.UpdateFPS()
 			// Prime movement counter so the next movement reflects the new speed promptly
 			newInterval := int(60 / float64(snake.Speed))
 			if snake.Direction == up || snake.Direction == down {
@@ -162,10 +166,14 @@ func (snake *Snake) Draw(screen *tl.Screen) {
 
 	// Always render the snake (60 FPS rendering)
 	for _, c := range snake.Bodylength {
-		screen.RenderCell(c.X, c.Y, &tl.Cell{
+		cell := &tl.Cell{
 			Bg: CheckSelectedColor(counterSnake),
 			// Ch: '▯',
-		})
+		}
+		if !snake.game.Display.Colors {
+			cell.Ch = snake.game.Display.block()
+		}
+		screen.RenderCell(c.X, c.Y, cell)
 	}
 }
 
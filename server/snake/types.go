@@ -1,14 +1,45 @@
 package snake
 
-import tl "github.com/JoelOtter/termloop"
+import (
+	"sync"
 
-// Game Object Variables.
-var (
-	sg  *tl.Game
-	sp  *Sidepanel
-	gs  *Gamescreen
+	tl "github.com/JoelOtter/termloop"
 )
 
+// Game holds the objects of a single running game. Every session gets its own
+// Game so that several visitors can play at the same time.
+type Game struct {
+	sg *tl.Game
+	sp *Sidepanel
+	gs *Gamescreen
+
+	// mu guards the score, which SaveScore reads from outside the game loop
+	mu sync.Mutex
+
+	// OnGameover, if set, is called with the final score of every round.
+	OnGameover func(score int)
+
+	// Display says what the player's terminal can show. Set it before Start.
+	Display Display
+}
+
+// Display describes the player's terminal. The zero value is a plain ASCII
+// terminal without colors.
+type Display struct {
+	Colors   bool // ANSI background colors for the snake and the arena
+	Unicode  bool // characters beyond ASCII
+	NerdFont bool // Nerd Font glyphs for the food
+}
+
+// block returns the character the snake and the arena are drawn with when
+// there are no colors to paint them.
+func (d Display) block() rune {
+	if d.Unicode {
+		return '█'
+	}
+	return '#'
+}
+
 // Own created types.
 type (
 	direction   int
@@ -43,6 +74,7 @@ const (
 
 type Gameoverscreen struct {
 	tl.Level
+	game              *Game
 	Logo              *tl.Entity
 	Finalstats        []*tl.Text
 	OptionsBackground *tl.Rectangle
@@ -69,6 +101,7 @@ type Sidepanel struct {
 
 type Arena struct {
 	*tl.Entity
+	game        *Game
 	Width       int
 	Height      int
 	ArenaBorder map[Coordinates]int
@@ -76,6 +109,7 @@ type Arena struct {
 
 type Snake struct {
 	*tl.Entity
+	game              *Game
 	Direction         direction
 	Length            int
 	Bodylength        []Coordinates
@@ -86,6 +120,7 @@ type Snake struct {
 
 type Food struct {
 	*tl.Entity
+	game         *Game
 	Foodposition Coordinates
 	Emoji        rune
 }
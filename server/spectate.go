@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxViewers caps the spectators of a single session.
+	maxViewers = 20
+	// viewerQueue is how many output frames a spectator may fall behind
+	// before it is dropped.
+	viewerQueue = 256
+)
+
+var errTooManyViewers = errors.New("this session already has too many spectators")
+
+// viewer is a spectator's feed. Output is queued so that a slow spectator
+// can't hold up the session; out is closed if it falls too far behind.
+type viewer struct {
+	out chan []byte
+}
+
+// watch adds a spectator, starting its feed with the current screen.
+func (s *session) watch() (*viewer, error) {
+	s.mu.Lock()
+	if len(s.viewers) >= maxViewers {
+		s.mu.Unlock()
+		return nil, errTooManyViewers
+	}
+	v := &viewer{out: make(chan []byte, viewerQueue)}
+	if snap := s.screen.snapshot(); len(snap) > 0 {
+		v.out <- snap
+	}
+	s.viewers[v] = struct{}{}
+	s.mu.Unlock()
+	s.notifyViewers()
+	return v, nil
+}
+
+// unwatch removes a spectator added by watch.
+func (s *session) unwatch(v *viewer) {
+	s.mu.Lock()
+	_, ok := s.viewers[v]
+	if ok {
+		delete(s.viewers, v)
+		close(v.out)
+	}
+	s.mu.Unlock()
+	if ok {
+		s.notifyViewers()
+	}
+}
+
+// feedViewers queues b for every spectator, dropping those that fall behind.
+// It reports whether any were dropped, for the caller to send the new count
+// once s.mu is released. Called with s.mu held.
+func (s *session) feedViewers(b []byte) (dropped bool) {
+	for v := range s.viewers {
+		select {
+		case v.out <- b:
+		default:
+			delete(s.viewers, v)
+			close(v.out)
+			dropped = true
+		}
+	}
+	return dropped
+}
+
+// notifyViewers tells the attached client how many spectators are watching.
+// The count is read under s.mu but sent after releasing it, so a slow client
+// can't hold up spectators joining or the session's output.
+func (s *session) notifyViewers() {
+	s.mu.Lock()
+	control, count := s.control, len(s.viewers)
+	s.mu.Unlock()
+	if control != nil {
+		_ = control(msgViewers, viewersMsg{Count: count})
+	}
+}
+
+// handleWatch streams a running session to a read-only spectator. It speaks
+// the same protocol as /ws, but input and resize messages are ignored.
+func handleWatch(w http.ResponseWriter, r *http.Request) {
+	transports.Add(1)
+	defer transports.Done()
+	id := r.PathValue("id")
+	raw, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn("watch: upgrade failed", "err", err)
+		return
+	}
+	defer raw.Close()
+	raw.SetReadLimit(maxInputBytes)
+	conn := &wsConn{conn: raw}
+
+	if _, err := conn.handshake(); err != nil {
+		slog.Warn("watch: handshake failed", "ip", requestIP(r), "err", err)
+		code := closeProtocolError
+		if errors.Is(err, errVersionMismatch) {
+			code = closeVersionMismatch
+		}
+		conn.close(code, err.Error())
+		return
+	}
+
+	sess := sessions.lookup(id)
+	if sess == nil {
+		conn.close(closeNotFound, "no such session")
+		return
+	}
+	v, err := sess.watch()
+	if err != nil {
+		conn.close(closeRejected, err.Error())
+		return
+	}
+	defer sess.unwatch(v)
+	// Server log only: the session logger would show the spectator's IP
+	// in the watched visitor's browser console
+	slog.Info("watch: spectator joined", "session", sess.id, "ip", requestIP(r))
+	_ = conn.sendControl(msgTitle, titleMsg{Title: fmt.Sprintf("watching %s", id)})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go conn.heartbeat(ctx)
+
+	// Reader pump → only keepalives are answered
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			msgType, data, err := raw.ReadMessage()
+			if err != nil {
+				return
+			}
+			_ = raw.SetReadDeadline(time.Now().Add(pongWait))
+			if msgType != websocket.TextMessage {
+				continue
+			}
+			env, err := decodeControl(data)
+			if err != nil {
+				continue
+			}
+			switch env.Type {
+			case msgPing:
+				var pm pingMsg
+				_ = env.decodePayload(&pm)
+				_ = conn.sendControl(msgPong, pm)
+			case msgClose:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case b, ok := <-v.out:
+			if !ok {
+				conn.close(closeNormal, "fell too far behind the session")
+				return
+			}
+			if err := conn.sendOutput(b); err != nil {
+				return
+			}
+		case <-readerDone:
+			return
+		case <-sess.done:
+			// Send what the session printed last before saying goodbye
+		drain:
+			for {
+				select {
+				case b, ok := <-v.out:
+					if !ok {
+						break drain
+					}
+					_ = conn.sendOutput(b)
+				default:
+					break drain
+				}
+			}
+			conn.close(closeNormal, "session ended")
+			return
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The SSE transport is for networks that block WebSocket upgrades. The
+// client opens an event stream with GET /sse, passing what /ws takes in its
+// hello as query parameters (version, client and caps, the latter as JSON)
+// except the resume token, which goes in resumeCookie to stay out of access
+// logs. It POSTs control messages to /sse/input with the session token in
+// sessionTokenHeader.
+//
+// The stream carries the same control messages as /ws, one JSON envelope
+// per "control" event, and terminal output base64-encoded in "output"
+// events. It ends with a close message, like /ws.
+
+// sessionTokenHeader names the session a POST to /sse/input is for.
+const sessionTokenHeader = "X-Session-Token"
+
+// resumeCookie carries the token of the session GET /sse should resume.
+// EventSource can't send headers, but it does send cookies. The server
+// clears it once read.
+const resumeCookie = "portfolio_resume"
+
+// maxInputBytes caps one message from a client: a WebSocket frame or the body
+// of a POST to /sse/input.
+const maxInputBytes = 64 << 10
+
+// errStreamClosed is returned for writes after the stream has ended.
+var errStreamClosed = errors.New("event stream closed")
+
+// sseConn serializes writes to an event stream, which are produced by the
+// output pump, loggers and the heartbeat. Once closed it drops them, since
+// the ResponseWriter is gone when the handler returns.
+type sseConn struct {
+	mu     sync.Mutex
+	w      io.Writer
+	rc     *http.ResponseController
+	closed bool
+}
+
+func (c *sseConn) write(s string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errStreamClosed
+	}
+	_ = c.rc.SetWriteDeadline(time.Now().Add(writeWait))
+	_, err := io.WriteString(c.w, s)
+	if err == nil {
+		err = c.rc.Flush()
+	}
+	if err != nil {
+		// The heartbeat finds the stream closed and detaches the session
+		c.closed = true
+	}
+	return err
+}
+
+// sendControl writes a control message as a "control" event.
+func (c *sseConn) sendControl(typ string, payload any) error {
+	data, err := encodeControl(typ, payload)
+	if err != nil {
+		return err
+	}
+	return c.write("event: control\ndata: " + string(data) + "\n\n")
+}
+
+// sendOutput writes terminal output as an "output" event.
+func (c *sseConn) sendOutput(b []byte) error {
+	return c.write("event: output\ndata: " + base64.StdEncoding.EncodeToString(b) + "\n\n")
+}
+
+// close tells the client why the session ends and drops later writes.
+func (c *sseConn) close(code int, reason string) {
+	_ = c.sendControl(msgClose, closeMsg{Code: code, Reason: reason})
+	c.shut()
+}
+
+func (c *sseConn) shut() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+}
+
+func handleSSE(w http.ResponseWriter, r *http.Request) {
+	transports.Add(1)
+	defer transports.Done()
+	if !admission.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	q := r.URL.Query()
+	version, _ := strconv.Atoi(q.Get("version"))
+	hello := helloMsg{Version: version, Client: q.Get("client")}
+	if c, err := r.Cookie(resumeCookie); err == nil {
+		hello.Resume = c.Value
+		http.SetCookie(w, &http.Cookie{Name: resumeCookie, Path: "/sse", MaxAge: -1})
+	}
+	if caps := q.Get("caps"); caps != "" {
+		hello.Caps = new(capabilities)
+		if err := json.Unmarshal([]byte(caps), hello.Caps); err != nil {
+			http.Error(w, "invalid caps: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keep nginx and friends from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	conn := &sseConn{w: w, rc: http.NewResponseController(w)}
+	defer conn.shut()
+
+	if hello.Version != protocolVersion {
+		slog.Warn("sse: handshake failed", "ip", requestIP(r), "err", errVersionMismatch)
+		conn.close(closeVersionMismatch, errVersionMismatch.Error())
+		return
+	}
+	_ = conn.sendControl(msgHello, helloMsg{Version: protocolVersion, Client: "tui-portfolio"})
+
+	sess, resumed, err := openSession(hello.Resume, hello.capabilities(), requestIP(r), "sse")
+	if err != nil {
+		conn.close(closeRejected, err.Error())
+		return
+	}
+
+	consoleLogger := sess.logger
+	consoleLogger.setControl(conn.sendControl)
+	_ = conn.sendControl(msgSession, sessionMsg{ID: sess.id, Token: sess.token, Resumed: resumed})
+	_ = conn.sendControl(msgTitle, titleMsg{Title: strings.TrimSpace(PROMPT)})
+	if resumed {
+		consoleLogger.Info("Resumed session", "ip", requestIP(r), "transport", "sse")
+	} else {
+		consoleLogger.Info("SSE connection established", "protocol", hello.Version, "client", hello.Client, "ip", requestIP(r))
+	}
+	replaced := sess.attach(conn.sendOutput, conn.sendControl)
+
+	// Comments keep proxies from timing out an idle stream; a failed write
+	// means the client is gone
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := conn.write(": ping\n\n"); err != nil {
+				sess.detach(replaced)
+				return
+			}
+		case <-r.Context().Done():
+			// Connection lost: keep the session around for a reconnect
+			sess.detach(replaced)
+			return
+		case <-replaced:
+			conn.close(closeNormal, "session resumed in another window")
+			return
+		case <-sess.done:
+			reason := sess.reason()
+			if reason == "" {
+				reason = "session ended"
+			}
+			conn.close(sess.closeCode(), reason)
+			return
+		}
+	}
+}
+
+// handleSSEInput takes one control message for the session of an SSE
+// stream, as the stream's client would send it over /ws.
+func handleSSEInput(w http.ResponseWriter, r *http.Request) {
+	sess := sessions.resume(r.Header.Get(sessionTokenHeader))
+	if sess == nil {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxInputBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	env, err := decodeControl(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if env.Type == msgHello {
+		http.Error(w, fmt.Sprintf("%s belongs in the query of /sse", msgHello), http.StatusBadRequest)
+		return
+	}
+	sess.handleControl(env, sess.sendControl)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	// sshAddr is the SSH listen address. Empty disables the SSH frontend.
+	sshAddr string
+	// sshHostKeyPath is the PEM file holding the server's host key. A new
+	// ed25519 key is generated there on first start.
+	sshHostKeyPath string
+)
+
+// ptyRequest is the payload of an SSH "pty-req" request (RFC 4254 6.2).
+type ptyRequest struct {
+	Term   string
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+	Modes  string
+}
+
+// envRequest is the payload of an SSH "env" request (RFC 4254 6.4).
+type envRequest struct {
+	Name  string
+	Value string
+}
+
+// windowChange is the payload of an SSH "window-change" request (RFC 4254 6.7).
+type windowChange struct {
+	Cols   uint32
+	Rows   uint32
+	Width  uint32
+	Height uint32
+}
+
+// newSSHConfig builds the server configuration. Visitors are anonymous, so
+// no client authentication is required.
+func newSSHConfig(hostKeyPath string) (*ssh.ServerConfig, error) {
+	signer, err := loadHostKey(hostKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+	return config, nil
+}
+
+// loadHostKey reads the host key at path, generating one if the file does not exist.
+func loadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, genErr := ed25519.GenerateKey(rand.Reader)
+		if genErr != nil {
+			return nil, genErr
+		}
+		block, genErr := ssh.MarshalPrivateKey(key, "tui-portfolio host key")
+		if genErr != nil {
+			return nil, genErr
+		}
+		data = pem.EncodeToMemory(block)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return nil, err
+		}
+		slog.Info("ssh: generated host key", "path", path)
+	} else if err != nil {
+		return nil, err
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// serveSSH accepts SSH connections on ln until it is closed.
+func serveSSH(ln net.Listener, config *ssh.ServerConfig) {
+	for {
+		nConn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("ssh: accept failed", "err", err)
+			}
+			return
+		}
+		go handleSSHConn(nConn, config)
+	}
+}
+
+func handleSSHConn(nConn net.Conn, config *ssh.ServerConfig) {
+	// A client that never finishes the key exchange would otherwise hold
+	// the connection forever without ever reaching admission
+	_ = nConn.SetDeadline(time.Now().Add(handshakeTimeout))
+	sconn, chans, reqs, err := ssh.NewServerConn(nConn, config)
+	if err != nil {
+		slog.Warn("ssh: handshake failed", "remote", nConn.RemoteAddr().String(), "err", err)
+		nConn.Close()
+		return
+	}
+	_ = nConn.SetDeadline(time.Time{})
+	defer sconn.Close()
+	slog.Info("ssh: connection", "remote", sconn.RemoteAddr().String(), "client", string(sconn.ClientVersion()))
+
+	go ssh.DiscardRequests(reqs)
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			newCh.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		ch, requests, err := newCh.Accept()
+		if err != nil {
+			slog.Warn("ssh: channel rejected", "err", err)
+			continue
+		}
+		go handleSSHChannel(ch, requests, clientIP(sconn.RemoteAddr().String()))
+	}
+}
+
+// handleSSHChannel runs a session over one SSH session channel. The PTY size
+// and window changes feed the session's size source.
+func handleSSHChannel(ch ssh.Channel, requests <-chan *ssh.Request, ip string) {
+	transports.Add(1)
+	defer transports.Done()
+	defer ch.Close()
+
+	// Output goes to the channel; SSH clients expect CRLF line endings
+	sess := newSession(context.Background(), lfToCRLF(func(b []byte) error {
+		_, err := ch.Write(b)
+		return err
+	}))
+
+	var started sync.Once
+	done := make(chan struct{})
+	start := func() {
+		go func() {
+			defer close(done)
+			sess.run()
+			if reason := sess.reason(); reason != "" {
+				ch.Write([]byte("\r\n[" + reason + "]\r\n"))
+			}
+			ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+			ch.Close()
+		}()
+		// Reader pump → keystrokes
+		go func() {
+			defer sess.close()
+			buf := make([]byte, 1024)
+			for {
+				n, err := ch.Read(buf)
+				if n > 0 {
+					sess.input(buf[:n])
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	// The terminal type and environment the client sends, for guessing its
+	// capabilities
+	env := make(map[string]string)
+	shellRequested := false
+	for req := range requests {
+		switch req.Type {
+		case "pty-req":
+			var pty ptyRequest
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			env["TERM"] = pty.Term
+			sess.size.set(int(pty.Cols), int(pty.Rows))
+			req.Reply(true, nil)
+		case "window-change":
+			var wc windowChange
+			if err := ssh.Unmarshal(req.Payload, &wc); err == nil {
+				sess.size.set(int(wc.Cols), int(wc.Rows))
+			}
+			req.Reply(true, nil)
+		case "shell":
+			// One shell per channel; admitting another would take a slot
+			// that is never released
+			if shellRequested {
+				req.Reply(false, nil)
+				continue
+			}
+			shellRequested = true
+			req.Reply(true, nil)
+			release, err := admission.admit(ip, "ssh")
+			if err != nil {
+				ch.Write([]byte(err.Error() + "\r\n"))
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{1}))
+				ch.Close()
+				continue
+			}
+			started.Do(func() {
+				sess.visitor, sess.frontend = journal.visitor(ip), "ssh"
+				sess.setCaps(capabilitiesFromEnv(func(name string) string { return env[name] }))
+				start()
+				go func() {
+					<-done
+					release()
+				}()
+			})
+		case "env":
+			var ev envRequest
+			if err := ssh.Unmarshal(req.Payload, &ev); err == nil && ev.Name != "TERM" {
+				env[ev.Name] = ev.Value
+			}
+			req.Reply(true, nil)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+
+	// The client closed the channel
+	sess.close()
+	started.Do(func() { close(done) })
+	<-done
+}
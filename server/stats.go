@@ -0,0 +1,275 @@
+//go:build !js
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// retentionWindow is how soon a visitor must come back to count as retained.
+const retentionWindow = 7 * 24 * time.Hour
+
+// journalSession is what the report needs to know about one session.
+type journalSession struct {
+	visitor  string
+	start    time.Time
+	commands int
+	sections map[string]bool
+	played   bool
+}
+
+// sectionStats aggregates the views of one portfolio section.
+type sectionStats struct {
+	name     string
+	views    int
+	visitors map[string]bool
+	time     time.Duration
+}
+
+// journalStats aggregates a journal. Sessions whose connect event is missing,
+// e.g. because they started before the journal was enabled, are left out.
+type journalStats struct {
+	first, last time.Time
+	sessions    map[string]*journalSession
+	sections    map[string]*sectionStats
+	skipped     int // lines that weren't valid events
+}
+
+// readJournal aggregates the events in r.
+func readJournal(r io.Reader) (*journalStats, error) {
+	st := &journalStats{sessions: make(map[string]*journalSession), sections: make(map[string]*sectionStats)}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		var ev journalEvent
+		if err := json.Unmarshal(sc.Bytes(), &ev); err != nil || ev.Session == "" {
+			st.skipped++
+			continue
+		}
+		if st.first.IsZero() || ev.Time.Before(st.first) {
+			st.first = ev.Time
+		}
+		if ev.Time.After(st.last) {
+			st.last = ev.Time
+		}
+		if ev.Event == eventConnect {
+			st.sessions[ev.Session] = &journalSession{visitor: ev.Visitor, start: ev.Time, sections: make(map[string]bool)}
+			continue
+		}
+		s := st.sessions[ev.Session]
+		if s == nil {
+			continue
+		}
+		switch ev.Event {
+		case eventCommand:
+			s.commands++
+		case eventSection:
+			s.sections[ev.Section] = true
+			sec := st.sections[ev.Section]
+			if sec == nil {
+				sec = &sectionStats{name: ev.Section, visitors: make(map[string]bool)}
+				st.sections[ev.Section] = sec
+			}
+			sec.views++
+			sec.visitors[s.visitor] = true
+			sec.time += time.Duration(ev.Duration * float64(time.Second))
+		case eventGame:
+			s.played = true
+		}
+	}
+	return st, sc.Err()
+}
+
+// report writes the top sections, the funnel from connecting to playing
+// snake, visitor retention and the daily visitors of the last days days.
+func (st *journalStats) report(w io.Writer, top, days int) {
+	if len(st.sessions) == 0 {
+		fmt.Fprintln(w, "No sessions in the journal.")
+		return
+	}
+	visitors := make(map[string][]time.Time) // visitor -> session starts
+	for _, s := range st.sessions {
+		visitors[s.visitor] = append(visitors[s.visitor], s.start)
+	}
+	fmt.Fprintf(w, "%d sessions from %d visitors, %s to %s\n", len(st.sessions), len(visitors), st.first.Format(time.DateOnly), st.last.Format(time.DateOnly))
+	if st.skipped > 0 {
+		fmt.Fprintf(w, "(%d unreadable lines skipped)\n", st.skipped)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\nTop sections:")
+	sections := make([]*sectionStats, 0, len(st.sections))
+	for _, sec := range st.sections {
+		sections = append(sections, sec)
+	}
+	sort.Slice(sections, func(i, j int) bool {
+		if sections[i].views != sections[j].views {
+			return sections[i].views > sections[j].views
+		}
+		return sections[i].name < sections[j].name
+	})
+	fmt.Fprintln(tw, "  section\tviews\tvisitors\tavg time")
+	for i, sec := range sections {
+		if i == top {
+			break
+		}
+		avg := sec.time / time.Duration(sec.views)
+		fmt.Fprintf(tw, "  %s\t%d\t%d\t%s\n", sec.name, sec.views, len(sec.visitors), avg.Round(time.Second))
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w, "\nFunnel:")
+	var commanded, viewed, browsed, played int
+	for _, s := range st.sessions {
+		if s.commands > 0 {
+			commanded++
+		}
+		if len(s.sections) > 0 {
+			viewed++
+		}
+		if len(s.sections) > 1 {
+			browsed++
+		}
+		if s.played {
+			played++
+		}
+	}
+	for _, step := range []struct {
+		name string
+		n    int
+	}{
+		{"connected", len(st.sessions)},
+		{"ran a command", commanded},
+		{"viewed a section", viewed},
+		{"viewed several sections", browsed},
+		{"played snake", played},
+	} {
+		fmt.Fprintf(tw, "  %s\t%d\t%s\n", step.name, step.n, percent(step.n, len(st.sessions)))
+	}
+	tw.Flush()
+
+	// A visitor is retained if they came back within retentionWindow of
+	// their first session; only visitors seen that long ago can tell
+	fmt.Fprintln(w, "\nRetention:")
+	var returning, eligible, retained int
+	for _, starts := range visitors {
+		sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+		firstDay := starts[0].Format(time.DateOnly)
+		cameBack := false
+		for _, t := range starts[1:] {
+			if t.Format(time.DateOnly) != firstDay {
+				returning++
+				cameBack = t.Sub(starts[0]) <= retentionWindow
+				break
+			}
+		}
+		if st.last.Sub(starts[0]) >= retentionWindow {
+			eligible++
+			if cameBack {
+				retained++
+			}
+		}
+	}
+	fmt.Fprintf(tw, "  returning visitors (seen on 2+ days)\t%d\t%s\n", returning, percent(returning, len(visitors)))
+	fmt.Fprintf(tw, "  back within %d days (of %d eligible)\t%d\t%s\n", retentionWindow/(24*time.Hour), eligible, retained, percent(retained, eligible))
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nDaily visitors (last %d days):\n", days)
+	dayVisitors := make(map[string]map[string]bool)
+	daySessions := make(map[string]int)
+	for _, s := range st.sessions {
+		day := s.start.Format(time.DateOnly)
+		if dayVisitors[day] == nil {
+			dayVisitors[day] = make(map[string]bool)
+		}
+		dayVisitors[day][s.visitor] = true
+		daySessions[day]++
+	}
+	fmt.Fprintln(tw, "  day\tvisitors\tsessions")
+	for i := days - 1; i >= 0; i-- {
+		day := st.last.AddDate(0, 0, -i).Format(time.DateOnly)
+		fmt.Fprintf(tw, "  %s\t%d\t%d\n", day, len(dayVisitors[day]), daySessions[day])
+	}
+	tw.Flush()
+}
+
+// percent formats n as a share of total.
+func percent(n, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(n)*100/float64(total))
+}
+
+// runStats implements the stats command, which prints a report of the
+// journal given as its argument or in PORTFOLIO_JOURNAL:
+//
+//	server stats [-top 10] [-days 14] [journal.jsonl]
+func runStats(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	top := fs.Int("top", 10, "number of sections to list")
+	days := fs.Int("days", 14, "number of days of daily visitors to list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	path := os.Getenv(envName("journal"))
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		return errors.New("stats: no journal given")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	st, err := readJournal(f)
+	if err != nil {
+		return fmt.Errorf("stats: %s: %w", path, err)
+	}
+	st.report(out, *top, *days)
+	return nil
+}
+
+// handleStats serves the stats report for the running server's journal.
+// The query takes the command's top and days options:
+//
+//	curl -H "Authorization: Bearer $TOKEN" 'https://host/admin/stats?days=30'
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	if journalPath == "" {
+		http.Error(w, "journal is disabled", http.StatusNotFound)
+		return
+	}
+	top, days := 10, 14
+	if v, err := strconv.Atoi(r.URL.Query().Get("top")); err == nil && v > 0 {
+		top = v
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && v > 0 && v <= 366 {
+		days = v
+	}
+	f, err := os.Open(journalPath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+	st, err := readJournal(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	st.report(w, top, days)
+}
@@ -0,0 +1,170 @@
+//go:build !js
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testJournal writes a journal with three visitors:
+//
+//   - alice visits on day 0, browses two sections and plays snake, and
+//     comes back on day 3
+//   - bob visits on day 0 and runs a command
+//   - carol visits on day 9 and views one section
+//
+// Its last event is on day 9, so alice and bob are eligible for retention.
+func testJournal(t *testing.T) string {
+	t.Helper()
+	day0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(days int, minutes int) time.Time {
+		return day0.AddDate(0, 0, days).Add(time.Duration(minutes) * time.Minute)
+	}
+	events := []journalEvent{
+		{Time: at(0, 0), Session: "a1", Event: eventConnect, Visitor: "alice", Frontend: "ws"},
+		{Time: at(0, 1), Session: "a1", Event: eventCommand, Command: "about"},
+		{Time: at(0, 2), Session: "a1", Event: eventSection, Section: "about", Duration: 30},
+		{Time: at(0, 3), Session: "a1", Event: eventSection, Section: "projects", Duration: 60},
+		{Time: at(0, 4), Session: "a1", Event: eventGame, Rounds: 2, Score: 7},
+		{Time: at(0, 5), Session: "b1", Event: eventConnect, Visitor: "bob", Frontend: "ssh"},
+		{Time: at(0, 6), Session: "b1", Event: eventCommand, Command: "help"},
+		{Time: at(0, 7), Session: "x1", Event: eventSection, Section: "about", Duration: 5}, // no connect
+		{Time: at(3, 0), Session: "a2", Event: eventConnect, Visitor: "alice", Frontend: "ws"},
+		{Time: at(3, 1), Session: "a2", Event: eventSection, Section: "about", Duration: 10},
+		{Time: at(9, 0), Session: "c1", Event: eventConnect, Visitor: "carol", Frontend: "telnet"},
+		{Time: at(9, 1), Session: "c1", Event: eventSection, Section: "projects", Duration: 20},
+	}
+	var buf bytes.Buffer
+	for _, ev := range events {
+		line, _ := json.Marshal(ev)
+		buf.Write(append(line, '\n'))
+	}
+	buf.WriteString("{truncated\n")
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadJournal(t *testing.T) {
+	f, err := os.Open(testJournal(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	st, err := readJournal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.sessions) != 4 || st.skipped != 1 {
+		t.Errorf("got %d sessions and %d skipped lines, want 4 and 1", len(st.sessions), st.skipped)
+	}
+	about := st.sections["about"]
+	if about == nil || about.views != 2 || len(about.visitors) != 1 || about.time != 40*time.Second {
+		t.Errorf("about = %+v, want 2 views by alice for 40s, without the session missing its connect", about)
+	}
+	if a1 := st.sessions["a1"]; !a1.played || a1.commands != 1 || len(a1.sections) != 2 {
+		t.Errorf("session a1 = %+v", a1)
+	}
+}
+
+// reportLine returns the fields of the report line starting with label.
+func reportLine(t *testing.T, report, label string) []string {
+	t.Helper()
+	for _, line := range strings.Split(report, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), label) {
+			return strings.Fields(strings.TrimPrefix(strings.TrimSpace(line), label))
+		}
+	}
+	t.Fatalf("report has no %q line:\n%s", label, report)
+	return nil
+}
+
+func TestStatsReport(t *testing.T) {
+	var out bytes.Buffer
+	if err := runStats([]string{"-days", "10", testJournal(t)}, &out); err != nil {
+		t.Fatal(err)
+	}
+	report := out.String()
+
+	if !strings.HasPrefix(report, "4 sessions from 3 visitors, 2026-03-01 to 2026-03-10\n(1 unreadable lines skipped)") {
+		t.Errorf("report starts with %q", strings.SplitN(report, "\n", 3)[:2])
+	}
+	tests := []struct {
+		label string
+		want  []string
+	}{
+		{"about", []string{"2", "1", "20s"}},
+		{"projects", []string{"2", "2", "40s"}},
+		{"connected", []string{"4", "100%"}},
+		{"ran a command", []string{"2", "50%"}},
+		{"viewed a section", []string{"3", "75%"}},
+		{"viewed several sections", []string{"1", "25%"}},
+		{"played snake", []string{"1", "25%"}},
+		{"returning visitors (seen on 2+ days)", []string{"1", "33%"}},
+		{"back within 7 days (of 2 eligible)", []string{"1", "50%"}},
+		{"2026-03-01", []string{"2", "2"}},
+		{"2026-03-04", []string{"1", "1"}},
+		{"2026-03-10", []string{"1", "1"}},
+	}
+	for _, tt := range tests {
+		if got := reportLine(t, report, tt.label); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: got %q, want %q", tt.label, got, tt.want)
+		}
+	}
+	if days := regexp.MustCompile(`(?m)^  2026-`).FindAllString(report, -1); len(days) != 10 {
+		t.Errorf("report lists %d days, want 10", len(days))
+	}
+}
+
+func TestStatsReportTop(t *testing.T) {
+	var out bytes.Buffer
+	if err := runStats([]string{"-top", "1", testJournal(t)}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(out.String(), "projects  ") {
+		t.Error("-top 1 listed a second section")
+	}
+}
+
+func TestRunStatsErrors(t *testing.T) {
+	t.Setenv(envName("journal"), "")
+	if err := runStats(nil, &bytes.Buffer{}); err == nil {
+		t.Error("runStats without a journal succeeded")
+	}
+	if err := runStats([]string{filepath.Join(t.TempDir(), "missing.jsonl")}, &bytes.Buffer{}); err == nil {
+		t.Error("runStats with a missing journal succeeded")
+	}
+}
+
+func TestHandleStats(t *testing.T) {
+	saved := journalPath
+	t.Cleanup(func() { journalPath = saved })
+
+	journalPath = ""
+	w := httptest.NewRecorder()
+	handleStats(w, httptest.NewRequest("GET", "/admin/stats", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("with the journal disabled, status = %d, want 404", w.Code)
+	}
+
+	journalPath = testJournal(t)
+	w = httptest.NewRecorder()
+	handleStats(w, httptest.NewRequest("GET", "/admin/stats?days=3&top=bad", nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "4 sessions from 3 visitors") {
+		t.Fatalf("got %d %q", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), "Daily visitors (last 3 days)") {
+		t.Error("days wasn't taken from the query")
+	}
+}
@@ -0,0 +1,23 @@
+//go:build !js
+
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// runTea runs the Bubble Tea credit card example until it quits or the
+// session ends.
+func (s *session) runTea() {
+	p := tea.NewProgram(initialModel(), tea.WithContext(s.ctx), tea.WithInput(s.in), tea.WithOutput(s.out), tea.WithAltScreen())
+
+	// Deliver the current size once the program is running, and every later one
+	stopResize := s.size.forward(func(sz termSize) {
+		p.Send(tea.WindowSizeMsg{Width: sz.Cols, Height: sz.Rows})
+	})
+	defer stopResize()
+
+	metrics.teaRuns.inc()
+	if _, err := p.Run(); err != nil && s.ctx.Err() == nil {
+		s.out.Write([]byte("error: "))
+		s.out.Write([]byte(err.Error()))
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "fmt"
+
+// runTea stands in for the Bubble Tea credit card example, which doesn't
+// build for the browser.
+func (s *session) runTea() {
+	fmt.Fprintln(s.out, "The credit card example needs the server version of this site.")
+}
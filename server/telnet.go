@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// telnetAddr is the Telnet listen address. Empty disables the Telnet frontend.
+var telnetAddr string
+
+// Telnet commands and options (RFC 854, 857, 858, 1073, 1091).
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetOptEcho  = 1
+	telnetOptSGA   = 3
+	telnetOptTTYPE = 24
+	telnetOptNAWS  = 31
+
+	telnetTTYPEIs   = 0
+	telnetTTYPESend = 1
+)
+
+// telnetTTYPEWait is how long a new connection waits for the client's
+// terminal type before the session starts without it.
+const telnetTTYPEWait = 500 * time.Millisecond
+
+// telnetGreeting asks the client to leave echo to us, drop go-aheads and
+// report its window size and terminal type.
+var telnetGreeting = []byte{
+	telnetIAC, telnetWILL, telnetOptEcho,
+	telnetIAC, telnetWILL, telnetOptSGA,
+	telnetIAC, telnetDO, telnetOptSGA,
+	telnetIAC, telnetDO, telnetOptNAWS,
+	telnetIAC, telnetDO, telnetOptTTYPE,
+}
+
+// telnetParser separates keystrokes from Telnet negotiation. IAC sequences
+// never reach the session; NAWS reports go to resize, the terminal type to
+// ttype ("" if the client won't say) and requests for options we don't
+// support are refused through reply.
+type telnetParser struct {
+	reply  func([]byte)
+	resize func(cols, rows int)
+	ttype  func(name string)
+
+	state  int
+	verb   byte
+	sb     []byte
+	prevCR bool
+}
+
+const (
+	telnetStateData = iota
+	telnetStateIAC
+	telnetStateOption
+	telnetStateSB
+	telnetStateSBIAC
+)
+
+// feed consumes raw bytes from the connection and returns the keystrokes in them.
+func (t *telnetParser) feed(in []byte) []byte {
+	out := make([]byte, 0, len(in))
+	for _, b := range in {
+		switch t.state {
+		case telnetStateData:
+			if b == telnetIAC {
+				t.state = telnetStateIAC
+				continue
+			}
+			// Enter arrives as CR LF or CR NUL; keep just the CR
+			if t.prevCR && (b == '\n' || b == 0) {
+				t.prevCR = false
+				continue
+			}
+			t.prevCR = b == '\r'
+			out = append(out, b)
+		case telnetStateIAC:
+			switch b {
+			case telnetIAC: // escaped 0xFF data byte
+				out = append(out, b)
+				t.state = telnetStateData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				t.verb = b
+				t.state = telnetStateOption
+			case telnetSB:
+				t.sb = t.sb[:0]
+				t.state = telnetStateSB
+			default: // NOP, GA and friends carry no data
+				t.state = telnetStateData
+			}
+		case telnetStateOption:
+			t.negotiate(t.verb, b)
+			t.state = telnetStateData
+		case telnetStateSB:
+			if b == telnetIAC {
+				t.state = telnetStateSBIAC
+				continue
+			}
+			t.sb = append(t.sb, b)
+		case telnetStateSBIAC:
+			switch b {
+			case telnetSE:
+				t.subnegotiation(t.sb)
+				t.state = telnetStateData
+			case telnetIAC:
+				t.sb = append(t.sb, b)
+				t.state = telnetStateSB
+			default:
+				t.state = telnetStateSB
+			}
+		}
+	}
+	return out
+}
+
+// negotiate answers a WILL/WONT/DO/DONT from the client. Options we offered
+// or asked for are acknowledged silently; everything else is refused.
+func (t *telnetParser) negotiate(verb, opt byte) {
+	switch verb {
+	case telnetDO:
+		if opt != telnetOptEcho && opt != telnetOptSGA {
+			t.reply([]byte{telnetIAC, telnetWONT, opt})
+		}
+	case telnetWILL:
+		switch opt {
+		case telnetOptSGA, telnetOptNAWS:
+		case telnetOptTTYPE:
+			t.reply([]byte{telnetIAC, telnetSB, telnetOptTTYPE, telnetTTYPESend, telnetIAC, telnetSE})
+		default:
+			t.reply([]byte{telnetIAC, telnetDONT, opt})
+		}
+	case telnetWONT:
+		if opt == telnetOptTTYPE {
+			t.ttype("")
+		}
+	}
+}
+
+func (t *telnetParser) subnegotiation(sb []byte) {
+	if len(sb) == 5 && sb[0] == telnetOptNAWS {
+		cols := int(sb[1])<<8 | int(sb[2])
+		rows := int(sb[3])<<8 | int(sb[4])
+		t.resize(cols, rows)
+	}
+	if len(sb) >= 2 && sb[0] == telnetOptTTYPE && sb[1] == telnetTTYPEIs {
+		t.ttype(string(sb[2:]))
+	}
+}
+
+// serveTelnet accepts Telnet connections on ln until it is closed.
+func serveTelnet(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Error("telnet: accept failed", "err", err)
+			}
+			return
+		}
+		go handleTelnet(conn)
+	}
+}
+
+// handleTelnet runs a session over a Telnet connection, the same way handleWS
+// does for a WebSocket.
+func handleTelnet(conn net.Conn) {
+	transports.Add(1)
+	defer transports.Done()
+	defer conn.Close()
+	slog.Info("telnet: connection", "remote", conn.RemoteAddr().String())
+
+	release, err := admission.admit(clientIP(conn.RemoteAddr().String()), "telnet")
+	if err != nil {
+		conn.Write([]byte(err.Error() + "\r\n"))
+		return
+	}
+	defer release()
+
+	// Negotiation replies and output come from different goroutines
+	var mu sync.Mutex
+	write := func(b []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := conn.Write(b)
+		return err
+	}
+
+	// Output: 0xFF must be doubled so it isn't read as IAC
+	sess := newSession(context.Background(), lfToCRLF(func(b []byte) error {
+		return write(bytes.ReplaceAll(b, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC}))
+	}))
+
+	if err := write(telnetGreeting); err != nil {
+		return
+	}
+
+	typed := make(chan string, 1)
+	parser := &telnetParser{
+		reply:  func(b []byte) { _ = write(b) },
+		resize: sess.size.set,
+		ttype: func(name string) {
+			select {
+			case typed <- name:
+			default:
+			}
+		},
+	}
+
+	// Reader pump → keystrokes and NAWS
+	go func() {
+		defer sess.close()
+		buf := make([]byte, 1024)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				if data := parser.feed(buf[:n]); len(data) > 0 {
+					sess.input(data)
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	// Telnet is 7-bit NVT unless BINARY is negotiated, which we don't, so
+	// the terminal type only tells the color depth
+	var term string
+	select {
+	case term = <-typed:
+	case <-time.After(telnetTTYPEWait):
+	}
+	caps := capabilitiesFromEnv(func(name string) string {
+		if name == "TERM" {
+			return term
+		}
+		return ""
+	})
+	if term == "" {
+		caps.Colors = 8
+	}
+	caps.Unicode, caps.NerdFont = false, false
+	sess.setCaps(caps)
+	sess.visitor, sess.frontend = journal.visitor(clientIP(conn.RemoteAddr().String())), "telnet"
+
+	sess.run()
+	if reason := sess.reason(); reason != "" {
+		_ = write([]byte("\r\n[" + reason + "]\r\n"))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// telnetRecorder collects everything a telnetParser reports.
+type telnetRecorder struct {
+	replies []byte
+	sizes   [][2]int
+	ttypes  []string
+}
+
+func (r *telnetRecorder) parser() *telnetParser {
+	return &telnetParser{
+		reply:  func(b []byte) { r.replies = append(r.replies, b...) },
+		resize: func(cols, rows int) { r.sizes = append(r.sizes, [2]int{cols, rows}) },
+		ttype:  func(name string) { r.ttypes = append(r.ttypes, name) },
+	}
+}
+
+func TestTelnetParserData(t *testing.T) {
+	tests := []struct {
+		name  string
+		feeds []string
+		want  string
+	}{
+		{"plain", []string{"ls"}, "ls"},
+		{"CR LF", []string{"ls\r\n"}, "ls\r"},
+		{"CR NUL", []string{"ls\r\x00"}, "ls\r"},
+		{"CR LF split", []string{"ls\r", "\nx"}, "ls\rx"},
+		{"bare LF", []string{"a\nb"}, "a\nb"},
+		{"two CRs", []string{"\r\r\n"}, "\r\r"},
+		{"escaped IAC", []string{"a\xff\xffb"}, "a\xffb"},
+		{"escaped IAC split", []string{"a\xff", "\xffb"}, "a\xffb"},
+		{"NOP dropped", []string{"a\xff\xf1b"}, "ab"},
+		{"negotiation dropped", []string{"a\xff\xfb\x03b"}, "ab"},
+		{"subnegotiation dropped", []string{"a\xff\xfa\x1f\x00\x50\x00\x18\xff\xf0b"}, "ab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec telnetRecorder
+			p := rec.parser()
+			var got []byte
+			for _, in := range tt.feeds {
+				got = append(got, p.feed([]byte(in))...)
+			}
+			if string(got) != tt.want {
+				t.Errorf("feed(%q) = %q, want %q", tt.feeds, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTelnetParserNAWS(t *testing.T) {
+	naws := []byte{telnetIAC, telnetSB, telnetOptNAWS, 0, 120, 0, 40, telnetIAC, telnetSE}
+	for split := 1; split < len(naws); split++ {
+		var rec telnetRecorder
+		p := rec.parser()
+		out := append(p.feed(naws[:split]), p.feed(naws[split:])...)
+		if len(out) != 0 {
+			t.Errorf("split at %d: NAWS leaked %q as data", split, out)
+		}
+		if len(rec.sizes) != 1 || rec.sizes[0] != [2]int{120, 40} {
+			t.Errorf("split at %d: got sizes %v, want [[120 40]]", split, rec.sizes)
+		}
+	}
+}
+
+func TestTelnetParserSubnegotiationIAC(t *testing.T) {
+	var rec telnetRecorder
+	p := rec.parser()
+	// A width of 255 columns is sent with its 0xFF doubled
+	out := p.feed([]byte{telnetIAC, telnetSB, telnetOptNAWS, 0, telnetIAC, telnetIAC, 0, 24, telnetIAC, telnetSE, 'x'})
+	if string(out) != "x" {
+		t.Errorf("got data %q, want %q", out, "x")
+	}
+	if len(rec.sizes) != 1 || rec.sizes[0] != [2]int{255, 24} {
+		t.Errorf("got sizes %v, want [[255 24]]", rec.sizes)
+	}
+}
+
+func TestTelnetParserTTYPE(t *testing.T) {
+	var rec telnetRecorder
+	p := rec.parser()
+	p.feed([]byte{telnetIAC, telnetWILL, telnetOptTTYPE})
+	want := []byte{telnetIAC, telnetSB, telnetOptTTYPE, telnetTTYPESend, telnetIAC, telnetSE}
+	if !bytes.Equal(rec.replies, want) {
+		t.Errorf("WILL TTYPE got reply %v, want %v", rec.replies, want)
+	}
+	p.feed(append([]byte{telnetIAC, telnetSB, telnetOptTTYPE, telnetTTYPEIs}, "XTERM-256COLOR\xff\xf0"...))
+	if len(rec.ttypes) != 1 || rec.ttypes[0] != "XTERM-256COLOR" {
+		t.Errorf("got terminal types %q, want [XTERM-256COLOR]", rec.ttypes)
+	}
+}
+
+func TestTelnetParserNegotiate(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want []byte
+	}{
+		{"DO ECHO", []byte{telnetIAC, telnetDO, telnetOptEcho}, nil},
+		{"DO SGA", []byte{telnetIAC, telnetDO, telnetOptSGA}, nil},
+		{"DO unknown", []byte{telnetIAC, telnetDO, 34}, []byte{telnetIAC, telnetWONT, 34}},
+		{"WILL SGA", []byte{telnetIAC, telnetWILL, telnetOptSGA}, nil},
+		{"WILL NAWS", []byte{telnetIAC, telnetWILL, telnetOptNAWS}, nil},
+		{"WILL unknown", []byte{telnetIAC, telnetWILL, 32}, []byte{telnetIAC, telnetDONT, 32}},
+		{"WONT unknown", []byte{telnetIAC, telnetWONT, 32}, nil},
+		{"DONT unknown", []byte{telnetIAC, telnetDONT, 32}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec telnetRecorder
+			p := rec.parser()
+			if out := p.feed(tt.in); len(out) != 0 {
+				t.Errorf("negotiation leaked %q as data", out)
+			}
+			if !bytes.Equal(rec.replies, tt.want) {
+				t.Errorf("got reply %v, want %v", rec.replies, tt.want)
+			}
+		})
+	}
+}
@@ -57,21 +57,6 @@ type Cell struct {
 	Ch rune
 }
 
-// Global IO configured by server
-
-var (
-	ioMu     sync.RWMutex
-	ioReader io.Reader
-	ioWriter io.Writer
-)
-
-func SetIO(r io.Reader, w io.Writer) {
-	ioMu.Lock()
-	defer ioMu.Unlock()
-	ioReader = r
-	ioWriter = w
-}
-
 // Entity base type and helpers used by the game code
 
 type Entity struct {
@@ -146,12 +131,23 @@ func (r *Rectangle) Draw(screen *Screen) {
 	}
 }
 
-// Screen collects cells and flushes frames to ioWriter
+// Screen collects cells and flushes frames to the game's writer
 
 type Screen struct {
 	mu    sync.Mutex
 	cells map[int]map[int]Cell
 	game  *Game
+	level any
+	base  *Level
+
+	width, height int
+}
+
+// Size returns the terminal size set with Game.Resize, or 0, 0 if unknown.
+func (s *Screen) Size() (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.width, s.height
 }
 
 func (s *Screen) RenderCell(x, y int, c *Cell) {
@@ -174,6 +170,7 @@ func (s *Screen) RenderCell(x, y int, c *Cell) {
 // Level stores a list of entities. Gamescreen embeds this.
 
 type Level struct {
+	mu       sync.RWMutex
 	entities []any
 	bg       Cell
 }
@@ -183,30 +180,56 @@ func NewBaseLevel(bg Cell) Level {
 }
 
 func (l *Level) AddEntity(e any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.entities = append(l.entities, e)
-	registerEntity(e)
 }
 
 func (l *Level) RemoveEntity(target any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	for i, e := range l.entities {
 		if e == target {
 			l.entities = append(l.entities[:i], l.entities[i+1:]...)
-			unregisterEntity(target)
 			return
 		}
 	}
 }
 
-// Game and loop
+// Entities returns a snapshot of the entities currently in the level.
+func (l *Level) Entities() []any {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]any(nil), l.entities...)
+}
+
+// baseLevel lets SetLevel find the Level embedded in a game's own level type.
+func (l *Level) baseLevel() *Level { return l }
+
+type levelProvider interface{ baseLevel() *Level }
+
+// Game and loop. Each Game owns its reader and writer so several games can
+// run side by side, one per connected session.
 
 type Game struct {
 	screen *Screen
 	fps    float64
 	quit   bool
+	in     io.Reader
+	out    io.Writer
+	colors bool
+}
+
+// Resize sets the terminal size frames are clipped to. It is safe to call
+// while the game runs; a zero size disables clipping.
+func (g *Game) Resize(w, h int) {
+	g.screen.mu.Lock()
+	defer g.screen.mu.Unlock()
+	g.screen.width, g.screen.height = w, h
 }
 
-func NewGame() *Game {
-	g := &Game{fps: 60}
+func NewGame(in io.Reader, out io.Writer) *Game {
+	g := &Game{fps: 60, in: in, out: out, colors: true}
 	s := &Screen{game: g, cells: make(map[int]map[int]Cell)}
 	g.screen = s
 	return g
@@ -214,8 +237,35 @@ func NewGame() *Game {
 
 func (g *Game) Screen() *Screen { return g.screen }
 
-func (s *Screen) SetLevel(level interface { /* marker */
-}) {
+// SetColors turns background colors on or off; without them frames are
+// plain text. Call it before Start.
+func (g *Game) SetColors(on bool) { g.colors = on }
+
+// SetLevel makes level the one drawn and ticked by the game loop. level is
+// usually a pointer to a type embedding Level; other values are ignored.
+func (s *Screen) SetLevel(level any) {
+	if s == nil {
+		return
+	}
+	lp, ok := level.(levelProvider)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.level = level
+	s.base = lp.baseLevel()
+}
+
+// current returns the active level and its entities.
+func (s *Screen) current() (any, []any) {
+	s.mu.Lock()
+	level, base := s.level, s.base
+	s.mu.Unlock()
+	if base == nil {
+		return level, nil
+	}
+	return level, base.Entities()
 }
 
 func (s *Screen) SetFps(f float64) {
@@ -246,16 +296,18 @@ func (e *Entity) Draw(screen *Screen) {
 	}
 }
 
-// event reader parses minimal keys from ioReader
+// event reader parses minimal keys from the game's reader
 
-func readEvents(ch chan Event, stop <-chan struct{}) {
-	ioMu.RLock()
-	r := ioReader
-	ioMu.RUnlock()
-	if r == nil {
-		return
-	}
+func readEvents(r io.Reader, ch chan Event, stop <-chan struct{}) {
 	br := bufio.NewReader(r)
+	send := func(ev Event) bool {
+		select {
+		case ch <- ev:
+			return true
+		case <-stop:
+			return false
+		}
+	}
 	for {
 		select {
 		case <-stop:
@@ -272,17 +324,17 @@ func readEvents(ch chan Event, stop <-chan struct{}) {
 				b3, _ := br.ReadByte()
 				switch b3 {
 				case 'A':
-					ch <- Event{Type: EventKey, Key: KeyArrowUp}
+					send(Event{Type: EventKey, Key: KeyArrowUp})
 				case 'B':
-					ch <- Event{Type: EventKey, Key: KeyArrowDown}
+					send(Event{Type: EventKey, Key: KeyArrowDown})
 				case 'C':
-					ch <- Event{Type: EventKey, Key: KeyArrowRight}
+					send(Event{Type: EventKey, Key: KeyArrowRight})
 				case 'D':
-					ch <- Event{Type: EventKey, Key: KeyArrowLeft}
+					send(Event{Type: EventKey, Key: KeyArrowLeft})
 				case '3':
 					// likely Delete: ESC [ 3 ~
 					_, _ = br.ReadByte() // consume '~'
-					ch <- Event{Type: EventKey, Key: KeyDelete}
+					send(Event{Type: EventKey, Key: KeyDelete})
 				}
 			}
 			continue
@@ -292,24 +344,37 @@ func readEvents(ch chan Event, stop <-chan struct{}) {
 			continue
 		}
 		rn := rune(b)
-		ch <- Event{Type: EventKey, Ch: rn}
+		if !send(Event{Type: EventKey, Ch: rn}) {
+			return
+		}
 	}
 }
 
 func (g *Game) Start() {
-	ioMu.RLock()
-	w := ioWriter
-	ioMu.RUnlock()
-	if w == nil {
+	w := g.out
+	if w == nil || g.in == nil {
 		return
 	}
 	// start input reader
 	stop := make(chan struct{})
 	evCh := make(chan Event, 16)
-	go readEvents(evCh, stop)
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		readEvents(g.in, evCh, stop)
+	}()
 
 	// simple frame loop
-	defer close(stop)
+	defer func() {
+		close(stop)
+		// Readers with deadlines (net.Conn style) are interrupted so the
+		// next program on the same input does not lose a keystroke to us.
+		if d, ok := g.in.(interface{ SetReadDeadline(time.Time) error }); ok {
+			_ = d.SetReadDeadline(time.Now())
+			<-readerDone
+			_ = d.SetReadDeadline(time.Time{})
+		}
+	}()
 	last := time.Now()
 	accum := 0.0
 	sp := time.Second / 60
@@ -321,7 +386,11 @@ func (g *Game) Start() {
 				if ev.Key == KeyDelete {
 					g.quit = true
 				}
-				broadcastTick(ev)
+				g.broadcastTick(ev)
+			case <-readerDone:
+				// The input is gone, e.g. the session ended
+				g.quit = true
+				goto afterDispatch
 			default:
 				goto afterDispatch
 			}
@@ -349,42 +418,15 @@ func max(a, b int) int {
 	return b
 }
 
-// simple global registry of entities added to any Level
-
-var (
-	globalMu sync.RWMutex
-	registry []any
-)
+// dispatch key events to the active level and any of its entities implementing Tick(Event)
 
-func globalEntities() []any {
-	globalMu.RLock()
-	defer globalMu.RUnlock()
-	return append([]any(nil), registry...)
-}
-
-func registerEntity(e any) {
-	globalMu.Lock()
-	registry = append(registry, e)
-	globalMu.Unlock()
-}
-
-func unregisterEntity(target any) {
-	globalMu.Lock()
-	for i, e := range registry {
-		if e == target {
-			registry = append(registry[:i], registry[i+1:]...)
-			break
-		}
+func (g *Game) broadcastTick(ev Event) {
+	level, ents := g.screen.current()
+	if t, ok := level.(ticker); ok {
+		t.Tick(ev)
 	}
-	globalMu.Unlock()
-}
-
-// dispatch key events to any entity implementing Tick(Event)
-
-func broadcastTick(ev Event) {
-	ents := globalEntities()
 	for _, e := range ents {
-		if t, ok := e.(interface{ Tick(Event) }); ok {
+		if t, ok := e.(ticker); ok {
 			t.Tick(ev)
 		}
 	}
@@ -398,7 +440,7 @@ func drawFrame(w io.Writer, screen *Screen) {
 	screen.cells = make(map[int]map[int]Cell)
 	screen.mu.Unlock()
 
-	ents := globalEntities()
+	_, ents := screen.current()
 	for _, e := range ents {
 		if d, ok := e.(drawable); ok {
 			d.Draw(screen)
@@ -434,6 +476,14 @@ func drawFrame(w io.Writer, screen *Screen) {
 			}
 		}
 	}
+	// clip to the terminal so rows do not wrap and scroll the frame;
+	// every row ends in a newline, so the last terminal row stays empty
+	if screen.width > 0 && maxX > minX+screen.width-1 {
+		maxX = minX + screen.width - 1
+	}
+	if screen.height > 1 && maxY > minY+screen.height-2 {
+		maxY = minY + screen.height - 2
+	}
 	// build frame with ANSI bg colors
 	var sb strings.Builder
 	sb.WriteString("\033[H\033[2J\033[?25l")
@@ -482,6 +532,9 @@ func drawFrame(w io.Writer, screen *Screen) {
 					bg = cell.Bg
 				}
 			}
+			if !screen.game.colors {
+				bg = ColorDefault
+			}
 			setBg(bg)
 			sb.WriteRune(ch)
 		}
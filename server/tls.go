@@ -0,0 +1,161 @@
+//go:build !js
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for changes.
+const certCheckInterval = 10 * time.Second
+
+// certReloader serves a certificate from files and picks up a rotated
+// certificate without a restart. The files are checked at most every
+// certCheckInterval, on the next handshake; if reloading fails the previous
+// certificate stays in use.
+type certReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// load reads the certificate and key. Called with r.mu held, or before r is shared.
+func (r *certReloader) load() error {
+	mod, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = mod
+	r.lastCheck = time.Now()
+	return nil
+}
+
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// getCertificate is the tls.Config GetCertificate callback.
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.lastCheck) >= certCheckInterval {
+		r.lastCheck = time.Now()
+		if mod, err := r.latestModTime(); err == nil && !mod.Equal(r.modTime) {
+			if err := r.load(); err != nil {
+				slog.Error("tls: reloading certificate failed, keeping the old one", "err", err)
+			} else {
+				slog.Info("tls: reloaded certificate", "cert", r.certFile)
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// selfSignedCertificate generates a throwaway certificate for localhost, for
+// trying wss:// during development.
+func selfSignedCertificate() (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "localhost", Organization: []string{"tui-portfolio development"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	fingerprint := sha256.Sum256(der)
+	slog.Warn("tls: using a self-signed certificate, browsers will ask to trust it", "sha256", hex.EncodeToString(fingerprint[:]))
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// newTLSConfig returns the server's TLS configuration, or nil if TLS is off.
+func newTLSConfig(c *Config) (*tls.Config, error) {
+	var get func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	switch {
+	case c.SelfSigned:
+		cert, err := selfSignedCertificate()
+		if err != nil {
+			return nil, err
+		}
+		get = func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return cert, nil }
+	case c.TLSCert != "":
+		r, err := newCertReloader(c.TLSCert, c.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		get = r.getCertificate
+	default:
+		return nil, nil
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: get}, nil
+}
+
+// httpsRedirect sends plain HTTP requests to the same URL on the TLS listener.
+func httpsRedirect(tlsAddr string) http.Handler {
+	_, tlsPort, _ := net.SplitHostPort(tlsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if tlsPort != "" && tlsPort != "443" {
+			host = net.JoinHostPort(host, tlsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// embeddedWeb holds the SvelteKit static build, written to web/build by
+// `npm run build`. Only web/README.md is there in a fresh checkout, in which
+// case the frontend routes answer 404.
+//
+//go:embed all:web
+var embeddedWeb embed.FS
+
+// webDir serves the frontend from this directory instead of the embedded
+// build, so a rebuilt frontend shows up without recompiling the server.
+var webDir string
+
+// webFS returns the filesystem the frontend is served from.
+func webFS() fs.FS {
+	if webDir != "" {
+		return os.DirFS(webDir)
+	}
+	sub, _ := fs.Sub(embeddedWeb, "web/build")
+	return sub
+}
+
+// newWebHandler serves the static frontend. Paths that don't name a file get
+// index.html so client-side routes survive a reload; missing assets are 404.
+func newWebHandler(fsys fs.FS) http.Handler {
+	files := http.FileServerFS(fsys)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
+		if info, err := fs.Stat(fsys, name); err != nil || info.IsDir() {
+			if path.Ext(name) != "" {
+				http.NotFound(w, r)
+				return
+			}
+			if _, err := fs.Stat(fsys, "index.html"); err != nil {
+				http.Error(w, "frontend not built", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Cache-Control", "no-cache")
+			http.ServeFileFS(w, r, fsys, "index.html")
+			return
+		}
+
+		// Vite fingerprints everything under _app/immutable; the rest keeps
+		// its name across builds and must be revalidated
+		if strings.HasPrefix(name, "_app/immutable/") {
+			w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		} else {
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		files.ServeHTTP(w, r)
+	})
+}
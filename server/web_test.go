@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestWebHandler(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":                   {Data: []byte("<!doctype html>index")},
+		"favicon.png":                  {Data: []byte("png")},
+		"_app/immutable/entry.abc1.js": {Data: []byte("js")},
+	}
+	h := newWebHandler(fsys)
+
+	tests := []struct {
+		method, path string
+		wantCode     int
+		wantBody     string
+		wantCache    string
+	}{
+		{"GET", "/", http.StatusOK, "index", "no-cache"},
+		{"GET", "/about", http.StatusOK, "index", "no-cache"},
+		{"GET", "/projects/snake/", http.StatusOK, "index", "no-cache"},
+		{"GET", "/_app", http.StatusOK, "index", "no-cache"},
+		{"GET", "/../../etc/passwd", http.StatusBadRequest, "", ""},
+		{"GET", "/favicon.png", http.StatusOK, "png", "no-cache"},
+		{"GET", "/_app/immutable/entry.abc1.js", http.StatusOK, "js", "public, max-age=31536000, immutable"},
+		{"GET", "/_app/immutable/gone.js", http.StatusNotFound, "", ""},
+		{"HEAD", "/about", http.StatusOK, "", "no-cache"},
+		{"POST", "/about", http.StatusMethodNotAllowed, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("body = %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+			if got := w.Header().Get("Cache-Control"); tt.wantCache != "" && got != tt.wantCache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.wantCache)
+			}
+			if tt.wantCode == http.StatusMethodNotAllowed && w.Header().Get("Allow") != "GET, HEAD" {
+				t.Errorf("Allow = %q", w.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestWebHandlerNotBuilt(t *testing.T) {
+	h := newWebHandler(fstest.MapFS{"README.md": {Data: []byte("build the frontend")}})
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/about", nil))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "frontend not built") {
+		t.Errorf("got %d %q, want 404 frontend not built", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return admission.checkOrigin(r) },
+}
+
+// handshakeTimeout bounds how long a new connection may take to send hello.
+const handshakeTimeout = 10 * time.Second
+
+// writeWait bounds every write to a client. A client that takes longer has
+// stalled; its connection is closed so the session detaches.
+const writeWait = 10 * time.Second
+
+// Keepalive: the server pings every pingInterval and drops connections that
+// have sent nothing, not even a pong, for pongWait.
+var (
+	pingInterval = 30 * time.Second
+	pongWait     = 75 * time.Second
+)
+
+// wsConn serializes writes to a WebSocket connection. gorilla/websocket allows
+// only one concurrent writer, and output, console logs and control replies are
+// produced by different goroutines.
+type wsConn struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (c *wsConn) write(msgType int, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	err := c.conn.WriteMessage(msgType, data)
+	if err != nil {
+		// Fails the reader pump too, which detaches the session
+		c.conn.Close()
+	}
+	return err
+}
+
+// sendControl writes a control message as a text frame.
+func (c *wsConn) sendControl(typ string, payload any) error {
+	data, err := encodeControl(typ, payload)
+	if err != nil {
+		return err
+	}
+	return c.write(websocket.TextMessage, data)
+}
+
+// sendOutput writes terminal output as a binary frame.
+func (c *wsConn) sendOutput(b []byte) error {
+	return c.write(websocket.BinaryMessage, b)
+}
+
+// heartbeat pings the client every pingInterval until ctx ends. Reads fail
+// once pongWait passes without any frame from the client, which ends the
+// reader pump and with it the session.
+func (c *wsConn) heartbeat(ctx context.Context) {
+	extend := func() { _ = c.conn.SetReadDeadline(time.Now().Add(pongWait)) }
+	extend()
+	c.conn.SetPongHandler(func(string) error {
+		extend()
+		return nil
+	})
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(handshakeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// close tells the client why the session ends, then closes the connection.
+func (c *wsConn) close(code int, reason string) {
+	_ = c.sendControl(msgClose, closeMsg{Code: code, Reason: reason})
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+}
+
+// handshake waits for the client's hello and answers with ours.
+func (c *wsConn) handshake() (helloMsg, error) {
+	var hello helloMsg
+	_ = c.conn.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	defer c.conn.SetReadDeadline(time.Time{})
+
+	msgType, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return hello, err
+	}
+	if msgType != websocket.TextMessage {
+		return hello, fmt.Errorf("expected hello, got binary frame")
+	}
+	env, err := decodeControl(data)
+	if err != nil {
+		return hello, err
+	}
+	if env.Type != msgHello {
+		return hello, fmt.Errorf("expected hello, got %q", env.Type)
+	}
+	if err := env.decodePayload(&hello); err != nil {
+		return hello, err
+	}
+	if hello.Version != protocolVersion {
+		return hello, errVersionMismatch
+	}
+	return hello, c.sendControl(msgHello, helloMsg{Version: protocolVersion, Client: "tui-portfolio"})
+}
+
+var errVersionMismatch = fmt.Errorf("unsupported protocol version, expected %d", protocolVersion)
+
+func handleWS(w http.ResponseWriter, r *http.Request) {
+	transports.Add(1)
+	defer transports.Done()
+	raw, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Warn("upgrade failed", "err", err)
+		return
+	}
+	defer raw.Close()
+	raw.SetReadLimit(maxInputBytes)
+	conn := &wsConn{conn: raw}
+
+	hello, err := conn.handshake()
+	if err != nil {
+		slog.Warn("handshake failed", "ip", requestIP(r), "err", err)
+		code := closeProtocolError
+		if errors.Is(err, errVersionMismatch) {
+			code = closeVersionMismatch
+		}
+		conn.close(code, err.Error())
+		return
+	}
+
+	sess, resumed, err := openSession(hello.Resume, hello.capabilities(), requestIP(r), "ws")
+	if err != nil {
+		conn.close(closeRejected, err.Error())
+		return
+	}
+
+	consoleLogger := sess.logger
+	consoleLogger.setControl(conn.sendControl)
+	_ = conn.sendControl(msgSession, sessionMsg{ID: sess.id, Token: sess.token, Resumed: resumed})
+	_ = conn.sendControl(msgTitle, titleMsg{Title: strings.TrimSpace(PROMPT)})
+	if resumed {
+		consoleLogger.Info("Resumed session", "ip", requestIP(r))
+	} else {
+		consoleLogger.Info("WebSocket connection established", "protocol", hello.Version, "client", hello.Client, "ip", requestIP(r))
+	}
+
+	// Output goes out as binary frames, cut at rune and escape boundaries,
+	// starting with a redraw of the current screen
+	replaced := sess.attach(conn.sendOutput, conn.sendControl)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go conn.heartbeat(ctx)
+
+	// Reader pump → control messages
+	readerDone := make(chan struct{})
+	go func() {
+		defer close(readerDone)
+		for {
+			msgType, data, err := raw.ReadMessage()
+			if err != nil {
+				return
+			}
+			// Any frame from the client shows it is still there
+			_ = raw.SetReadDeadline(time.Now().Add(pongWait))
+			if msgType != websocket.TextMessage {
+				consoleLogger.LogDebug("Ignoring binary frame from client")
+				continue
+			}
+			env, err := decodeControl(data)
+			if err != nil {
+				consoleLogger.LogError(err.Error())
+				continue
+			}
+			if !sess.handleControl(env, conn.sendControl) {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-readerDone:
+		// Connection lost: keep the session around for a reconnect
+		sess.detach(replaced)
+	case <-replaced:
+		conn.close(closeNormal, "session resumed in another window")
+	case <-sess.done:
+		reason := sess.reason()
+		if reason == "" {
+			reason = "session ended"
+		}
+		conn.close(sess.closeCode(), reason)
+	}
+}
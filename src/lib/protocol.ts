@@ -0,0 +1,73 @@
+// Control protocol spoken on /ws. Mirrors server/protocol.go.
+//
+// Text frames carry one JSON envelope each; terminal output arrives in binary
+// frames and is written to the terminal untouched.
+
+export const PROTOCOL_VERSION = 1;
+
+// Close code sent when the server shuts down; the client should reconnect.
+export const CLOSE_SERVICE_RESTART = 1012;
+
+// What the terminal can display, sent in the hello so the server can pick
+// glyphs and colors. Mirrors capabilities in server/capabilities.go.
+export type Capabilities = {
+	colors: number; // 0, 8, 16, 256 or 16777216 (truecolor)
+	unicode: boolean;
+	nerdfont: boolean;
+	mouse: boolean;
+	images?: string[];
+};
+
+// xterm.js does truecolor, Unicode and mouse reporting. The page font has no
+// Nerd Font glyphs, so visitors who have one installed opt in with ?nerdfont.
+export function terminalCapabilities(): Capabilities {
+	return {
+		colors: 16777216,
+		unicode: true,
+		nerdfont: new URLSearchParams(location.search).has('nerdfont'),
+		mouse: true
+	};
+}
+
+export type ControlMessage =
+	| {
+			type: 'hello';
+			payload: { version: number; client?: string; resume?: string; caps?: Capabilities };
+	  }
+	| { type: 'session'; payload: { id: string; token: string; resumed: boolean } }
+	| { type: 'input'; payload: { data: string } }
+	| { type: 'resize'; payload: { cols: number; rows: number } }
+	| { type: 'ping'; payload: { nonce: number } }
+	| { type: 'pong'; payload: { nonce: number } }
+	| { type: 'console'; payload: { level: string; message: string } }
+	| { type: 'title'; payload: { title: string } }
+	| { type: 'bell'; payload?: undefined }
+	| { type: 'download'; payload: { name: string; mime: string; data: string } }
+	| { type: 'viewers'; payload: { count: number } }
+	| { type: 'close'; payload: { code: number; reason: string } };
+
+export function send(ws: WebSocket | undefined, msg: ControlMessage) {
+	if (ws?.readyState === WebSocket.OPEN) {
+		ws.send(JSON.stringify(msg));
+	}
+}
+
+export function decode(data: string): ControlMessage | null {
+	try {
+		const msg = JSON.parse(data);
+		return typeof msg?.type === 'string' ? (msg as ControlMessage) : null;
+	} catch {
+		return null;
+	}
+}
+
+// offerDownload saves a base64 payload sent by the server as a file.
+export function offerDownload(name: string, mime: string, data: string) {
+	const bytes = Uint8Array.from(atob(data), (c) => c.charCodeAt(0));
+	const url = URL.createObjectURL(new Blob([bytes], { type: mime }));
+	const a = document.createElement('a');
+	a.href = url;
+	a.download = name;
+	a.click();
+	URL.revokeObjectURL(url);
+}
@@ -0,0 +1,88 @@
+// Fallback transport for networks that block WebSocket upgrades. Mirrors
+// server/sse.go: output and control messages arrive as server-sent events,
+// control messages from the client are POSTed to /sse/input.
+
+import {
+	PROTOCOL_VERSION,
+	decode,
+	terminalCapabilities,
+	type ControlMessage
+} from '$lib/protocol';
+
+export type SSEHandlers = {
+	onopen(): void;
+	onoutput(data: Uint8Array): void;
+	onmessage(msg: ControlMessage): void;
+	onclose(): void;
+};
+
+export class SSEConnection {
+	private source: EventSource;
+	private closed = false;
+	// Messages wait for the session token and go out one at a time, so
+	// keystrokes can't overtake each other
+	private token: Promise<string>;
+	private setToken!: (token: string) => void;
+	private queue: Promise<unknown> = Promise.resolve();
+
+	constructor(
+		resume: string | undefined,
+		private handlers: SSEHandlers
+	) {
+		this.token = new Promise((resolve) => (this.setToken = resolve));
+
+		const params = new URLSearchParams({
+			version: String(PROTOCOL_VERSION),
+			client: 'xterm.js',
+			caps: JSON.stringify(terminalCapabilities())
+		});
+		// The token goes in a short-lived cookie rather than the URL, which
+		// proxies and access logs record; the server clears it
+		if (resume) {
+			document.cookie = `portfolio_resume=${resume}; path=/sse; max-age=60; samesite=strict`;
+		}
+		this.source = new EventSource('/sse?' + params);
+		this.source.addEventListener('open', () => handlers.onopen());
+		this.source.addEventListener('output', (ev) => {
+			handlers.onoutput(Uint8Array.from(atob(ev.data), (c) => c.charCodeAt(0)));
+		});
+		this.source.addEventListener('control', (ev) => {
+			const msg = decode(ev.data);
+			if (!msg) {
+				return;
+			}
+			if (msg.type === 'session') {
+				this.setToken(msg.payload.token);
+			}
+			handlers.onmessage(msg);
+			if (msg.type === 'close') {
+				this.close();
+			}
+		});
+		// EventSource would reconnect by itself, but without the resume
+		// token; the page does that instead
+		this.source.addEventListener('error', () => this.close());
+	}
+
+	send(msg: ControlMessage) {
+		if (this.closed) {
+			return;
+		}
+		this.queue = Promise.all([this.queue, this.token]).then(([, token]) =>
+			fetch('/sse/input', {
+				method: 'POST',
+				headers: { 'Content-Type': 'application/json', 'X-Session-Token': token },
+				body: JSON.stringify(msg)
+			}).catch(() => undefined)
+		);
+	}
+
+	close() {
+		if (this.closed) {
+			return;
+		}
+		this.closed = true;
+		this.source.close();
+		this.handlers.onclose();
+	}
+}
@@ -0,0 +1,40 @@
+// Runs the shell compiled to WebAssembly (server/main_wasm.go) inside the
+// page, for static hosting without the Go server. Build it with
+// `npm run build:wasm`; /wasm_exec.js (loaded by app.html) must come from the
+// same Go version.
+
+import type { Terminal } from '@xterm/xterm';
+import { terminalCapabilities, type Capabilities } from '$lib/protocol';
+
+declare const Go: {
+	new (): { importObject: WebAssembly.Imports; run(instance: WebAssembly.Instance): Promise<void> };
+};
+
+// The object main_wasm.go puts on globalThis.
+type Shell = {
+	resize(cols: number, rows: number): void;
+	// Returns an Error, without starting, if its arguments are invalid.
+	start(write: (data: Uint8Array) => void, caps?: Capabilities): Error | null;
+	input(data: string): void;
+};
+
+export const WASM_URL = '/portfolio.wasm';
+
+// runOffline starts the shell in term and resolves once the session is over.
+export async function runOffline(term: Terminal): Promise<void> {
+	const go = new Go();
+	const { instance } = await WebAssembly.instantiateStreaming(fetch(WASM_URL), go.importObject);
+	const exited = go.run(instance);
+	const shell = (globalThis as unknown as { tuiPortfolio: Shell }).tuiPortfolio;
+
+	shell.resize(term.cols, term.rows);
+	const err = shell.start((data) => term.write(data), terminalCapabilities());
+	if (err) {
+		throw err;
+	}
+	const input = term.onData((d) => shell.input(d));
+	const resize = term.onResize(({ cols, rows }) => shell.resize(cols, rows));
+	await exited;
+	input.dispose();
+	resize.dispose();
+}
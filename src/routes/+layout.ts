@@ -0,0 +1,4 @@
+// The app is served as a single page by the Go server; the terminal needs a
+// browser, so nothing is rendered on the server or prerendered.
+export const ssr = false;
+export const prerender = false;
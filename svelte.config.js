@@ -6,7 +6,15 @@ const config = {
 	// Consult https://svelte.dev/docs/kit/integrations
 	// for more information about preprocessors
 	preprocess: vitePreprocess(),
-	kit: { adapter: adapter() }
+	kit: {
+		// Single-page build embedded by the Go server (server/web.go), which
+		// falls back to index.html for client-side routes
+		adapter: adapter({
+			pages: 'server/web/build',
+			assets: 'server/web/build',
+			fallback: 'index.html'
+		})
+	}
 };
 
 export default config;